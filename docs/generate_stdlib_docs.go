@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 
 	"github.com/ankyra/escape-core/script"
 )
@@ -17,13 +18,24 @@ func (t *Type) AddMethod(f script.StdlibFunc) {
 		t.Methods = map[string]string{}
 	}
 	header := fmt.Sprintf("%s(%s)", f.Id, f.Args)
+	if f.Returns != "" {
+		header = fmt.Sprintf("%s :: %s", header, f.Returns)
+	}
 	t.Methods[header] = f.Doc
 }
 
 func main() {
+	s := generateStdlibDocs(script.NewStdlibFunctionRegistry())
+	os.Mkdir("docs/generated/", 0755)
+	ioutil.WriteFile("docs/generated/stdlib.md", []byte(s), 0644)
+}
+
+// Generates the reference for the functions in the registry, so that
+// applications with a customised function set can document it as well.
+func generateStdlibDocs(registry *script.FunctionRegistry) string {
 	class := map[string]*Type{}
 
-	for _, f := range script.Stdlib {
+	for _, f := range registry.Functions() {
 		cls, found := class[f.ActsOn]
 		if !found {
 			cls = &Type{}
@@ -51,16 +63,30 @@ h2 {
 Standard library functions for the [Escape Scripting Language](../scripting-language/)
 
 `
-	for cls, typ := range class {
+	for _, cls := range sortedKeys(class) {
+		typ := class[cls]
 		if cls == "" {
 			s = fmt.Sprintf("%s\n# Unary functions\n\n", s)
 		} else {
 			s = fmt.Sprintf("%s\n# Functions acting on %s\n\n", s, cls)
 		}
-		for sig, doc := range typ.Methods {
-			s = fmt.Sprintf("%s## %s\n\n%s\n\n", s, sig, doc)
+		signatures := []string{}
+		for sig := range typ.Methods {
+			signatures = append(signatures, sig)
+		}
+		sort.Strings(signatures)
+		for _, sig := range signatures {
+			s = fmt.Sprintf("%s## %s\n\n%s\n\n", s, sig, typ.Methods[sig])
 		}
 	}
-	os.Mkdir("docs/generated/", 0755)
-	ioutil.WriteFile("docs/generated/stdlib.md", []byte(s), 0644)
+	return s
+}
+
+func sortedKeys(class map[string]*Type) []string {
+	keys := []string{}
+	for key := range class {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -231,6 +231,16 @@ func (e *ExecStage) String() string {
 	}
 }
 
+// Parses the script, command and arguments into the script cache.
+func (e *ExecStage) CompileScripts() error {
+	for _, str := range append([]string{e.RelativeScript, e.Cmd}, e.Args...) {
+		if _, err := script.ParseScriptCached(str); err != nil {
+			return fmt.Errorf("Couldn't parse expression in exec stage: %s in '%s'", err.Error(), str)
+		}
+	}
+	return nil
+}
+
 func (e *ExecStage) Eval(env *script.ScriptEnvironment) (*ExecStage, error) {
 	result := e.Copy()
 	relative, err := script.ParseAndEvalToString(e.RelativeScript, env)
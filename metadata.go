@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/ankyra/escape-core/parsers"
@@ -517,3 +518,171 @@ func (m *ReleaseMetadata) ToScriptMap() map[string]script.Script {
 		"id": script.LiftString(m.GetQualifiedReleaseId()),
 	}
 }
+
+// Returns the static type of the value that scripts can reference using
+// `$this`, or using the variable name when this release is a dependency.
+func (m *ReleaseMetadata) ToStaticType() *script.StaticType {
+	result := script.InferStaticType(m.ToScript())
+	inputs := map[string]*script.StaticType{}
+	for _, input := range m.Inputs {
+		inputs[input.Id] = input.GetStaticType()
+	}
+	outputs := map[string]*script.StaticType{}
+	for _, output := range m.Outputs {
+		outputs[output.Id] = output.GetStaticType()
+	}
+	result.Fields["inputs"] = script.NewMapStaticType(inputs)
+	result.Fields["outputs"] = script.NewMapStaticType(outputs)
+	for _, key := range []string{"project", "environment", "deployment"} {
+		result.Fields[key] = script.NewStaticType("string")
+	}
+	return result
+}
+
+// Type checks the scripts in the variable defaults, dependency mappings and
+// template mappings, without evaluating them. The dependencies map is keyed
+// on release ID; dependencies that are missing from the map can still be
+// referenced, but their fields won't be checked.
+func (m *ReleaseMetadata) TypeCheckScripts(dependencies map[string]*ReleaseMetadata) error {
+	globals := m.getStaticGlobals(dependencies)
+	for _, input := range m.Inputs {
+		if err := input.TypeCheckDefault(globals); err != nil {
+			return err
+		}
+	}
+	for _, output := range m.Outputs {
+		if err := output.TypeCheckDefault(globals); err != nil {
+			return err
+		}
+	}
+	for _, depend := range m.Depends {
+		for _, mapping := range []map[string]interface{}{depend.Mapping, depend.BuildMapping, depend.DeployMapping} {
+			if err := typeCheckMapping(mapping, globals); err != nil {
+				return fmt.Errorf("Invalid mapping for dependency '%s': %s", depend.ReleaseId, err.Error())
+			}
+		}
+	}
+	for _, tpl := range m.Templates {
+		if err := typeCheckMapping(tpl.Mapping, globals); err != nil {
+			return fmt.Errorf("Invalid mapping for template '%s': %s", tpl.File, err.Error())
+		}
+	}
+	return nil
+}
+
+// Parses all the expressions in the metadata into the script cache (see
+// script.DefaultScriptCache), so that evaluating the variables, stages and
+// mappings later on doesn't have to parse them again.
+func (m *ReleaseMetadata) CompileScripts() error {
+	for _, variable := range append(append([]*variables.Variable{}, m.Inputs...), m.Outputs...) {
+		if err := variable.CompileScripts(); err != nil {
+			return err
+		}
+	}
+	stageNames := []string{}
+	for name, _ := range m.Stages {
+		stageNames = append(stageNames, name)
+	}
+	sort.Strings(stageNames)
+	for _, stageName := range stageNames {
+		if err := m.Stages[stageName].CompileScripts(); err != nil {
+			return fmt.Errorf("Invalid stage '%s': %s", stageName, err.Error())
+		}
+	}
+	errandNames := []string{}
+	for name, _ := range m.Errands {
+		errandNames = append(errandNames, name)
+	}
+	sort.Strings(errandNames)
+	for _, errandName := range errandNames {
+		errand := m.Errands[errandName]
+		if errand.Run != nil {
+			if err := errand.Run.CompileScripts(); err != nil {
+				return fmt.Errorf("Invalid errand '%s': %s", errandName, err.Error())
+			}
+		}
+		for _, variable := range errand.Inputs {
+			if err := variable.CompileScripts(); err != nil {
+				return fmt.Errorf("Invalid errand '%s': %s", errandName, err.Error())
+			}
+		}
+	}
+	for _, depend := range m.Depends {
+		for _, mapping := range []map[string]interface{}{depend.Mapping, depend.BuildMapping, depend.DeployMapping} {
+			if err := compileMapping(mapping); err != nil {
+				return fmt.Errorf("Invalid mapping for dependency '%s': %s", depend.ReleaseId, err.Error())
+			}
+		}
+	}
+	for _, tpl := range m.Templates {
+		if err := compileMapping(tpl.Mapping); err != nil {
+			return fmt.Errorf("Invalid mapping for template '%s': %s", tpl.File, err.Error())
+		}
+	}
+	return nil
+}
+
+func compileMapping(mapping map[string]interface{}) error {
+	keys := []string{}
+	for key, _ := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		str, ok := mapping[key].(string)
+		if !ok {
+			continue
+		}
+		if _, err := script.ParseScriptCached(str); err != nil {
+			return fmt.Errorf("Couldn't parse expression for key '%s': %s in '%s'", key, err.Error(), str)
+		}
+	}
+	return nil
+}
+
+func (m *ReleaseMetadata) getStaticGlobals(dependencies map[string]*ReleaseMetadata) map[string]*script.StaticType {
+	globals := map[string]*script.StaticType{
+		"this": m.ToStaticType(),
+	}
+	for _, depend := range m.Depends {
+		typ := script.NewMapStaticType(nil)
+		if depMetadata, ok := dependencies[depend.ReleaseId]; ok && depMetadata != nil {
+			typ = depMetadata.ToStaticType()
+		}
+		globals[depend.ReleaseId] = typ
+		if depend.VariableName != "" {
+			globals[depend.VariableName] = typ
+		}
+	}
+	for _, consumer := range m.Consumes {
+		globals[consumer.VariableName] = script.NewMapStaticType(nil)
+	}
+	for key, _ := range m.VariableCtx {
+		if _, ok := globals[key]; !ok {
+			globals[key] = script.NewMapStaticType(nil)
+		}
+	}
+	return globals
+}
+
+func typeCheckMapping(mapping map[string]interface{}, globals map[string]*script.StaticType) error {
+	keys := []string{}
+	for key, _ := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		str, ok := mapping[key].(string)
+		if !ok {
+			continue
+		}
+		parsed, err := script.ParseScriptCached(str)
+		if err != nil {
+			return fmt.Errorf("Couldn't parse expression for key '%s': %s in '%s'", key, err.Error(), str)
+		}
+		if _, err := script.TypeCheck(parsed, globals); err != nil {
+			return fmt.Errorf("Type error for key '%s': %s in '%s'", key, err.Error(), str)
+		}
+	}
+	return nil
+}
@@ -21,6 +21,8 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/ankyra/escape-core/script"
+	"github.com/ankyra/escape-core/templates"
 	"github.com/ankyra/escape-core/variables"
 	. "gopkg.in/check.v1"
 )
@@ -334,3 +336,164 @@ func (s *metadataSuite) Test_AddConsumes(c *C) {
 	c.Assert(m.GetConsumes("build"), HasLen, 3)
 	c.Assert(m.GetConsumes("deploy"), HasLen, 3)
 }
+
+func newTypeCheckMetadata(c *C) *ReleaseMetadata {
+	m := NewReleaseMetadata("test", "1.0")
+	input, err := variables.NewVariableFromString("count", "integer")
+	c.Assert(err, IsNil)
+	m.AddInputVariable(input)
+	output, err := variables.NewVariableFromString("url", "string")
+	c.Assert(err, IsNil)
+	output.Default = "$dep.outputs.host.concat(\":\", $this.inputs.count)"
+	m.AddOutputVariable(output)
+	dep := NewDependencyConfig("_/dep-v1.0")
+	dep.VariableName = "dep"
+	dep.Mapping["name"] = "$this.name.upper()"
+	m.AddDependency(dep)
+	m.Templates = []*templates.Template{
+		templates.NewTemplateWithMapping("test.tpl", map[string]interface{}{"replicas": "$this.inputs.count * 2"}),
+	}
+	return m
+}
+
+func newTypeCheckDependency(c *C) *ReleaseMetadata {
+	dep := NewReleaseMetadata("dep", "1.0")
+	output, err := variables.NewVariableFromString("host", "string")
+	c.Assert(err, IsNil)
+	dep.AddOutputVariable(output)
+	return dep
+}
+
+func (s *metadataSuite) Test_TypeCheckScripts(c *C) {
+	m := newTypeCheckMetadata(c)
+	c.Assert(m.TypeCheckScripts(map[string]*ReleaseMetadata{"_/dep-v1.0": newTypeCheckDependency(c)}), IsNil)
+	c.Assert(m.TypeCheckScripts(nil), IsNil)
+}
+
+func (s *metadataSuite) Test_TypeCheckScripts_fails(c *C) {
+	deps := map[string]*ReleaseMetadata{"_/dep-v1.0": newTypeCheckDependency(c)}
+
+	m := newTypeCheckMetadata(c)
+	m.Outputs[0].Default = "$dep.outputs.unknown"
+	err := m.TypeCheckScripts(deps)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, "Type error in default field of variable 'url': Field 'unknown' was not found (host) in '$dep.outputs.unknown'")
+
+	m = newTypeCheckMetadata(c)
+	m.Depends[0].Mapping["name"] = "$this.inputs.count.upper()"
+	err = m.TypeCheckScripts(deps)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, "Invalid mapping for dependency '_/dep-v1.0': Type error for key 'name': 'upper' expects string for argument 1, got integer in '$this.inputs.count.upper()'")
+
+	m = newTypeCheckMetadata(c)
+	m.Templates[0].Mapping["replicas"] = "$this.name * 2"
+	err = m.TypeCheckScripts(deps)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, "Invalid mapping for template 'test.tpl': Type error for key 'replicas': 'multiply' expects integer|float for argument 1, got string in '$this.name * 2'")
+}
+
+func (s *metadataSuite) Test_CompileScripts(c *C) {
+	m := newTypeCheckMetadata(c)
+	m.Stages["deploy"] = &ExecStage{Cmd: "$this.inputs.cmd", Args: []string{"--replicas", "$this.inputs.count"}}
+	errand := NewErrand("backup", "", "Backup")
+	errand.Run = &ExecStage{Cmd: "backup.sh", Args: []string{"$this.outputs.url"}}
+	m.Errands["backup"] = errand
+
+	script.DefaultScriptCache.Clear()
+	c.Assert(m.CompileScripts(), IsNil)
+	compiled := script.DefaultScriptCache.Len()
+	for _, str := range []string{
+		m.Outputs[0].Default.(string),
+		"$this.name.upper()",
+		"$this.inputs.count * 2",
+		"$this.inputs.cmd",
+		"$this.inputs.count",
+		"$this.outputs.url",
+	} {
+		_, err := script.ParseScriptCached(str)
+		c.Assert(err, IsNil)
+		c.Assert(script.DefaultScriptCache.Len(), Equals, compiled, Commentf("'%s' should have been compiled", str))
+	}
+}
+
+func (s *metadataSuite) Test_CompileScripts_fails(c *C) {
+	m := newTypeCheckMetadata(c)
+	m.Outputs[0].Default = "$this.outputs.("
+	err := m.CompileScripts()
+	c.Assert(err, Not(IsNil))
+	c.Assert(err, ErrorMatches, "Couldn't parse expression in default field of variable 'url': .* in '\\$this.outputs.\\('")
+
+	m = newTypeCheckMetadata(c)
+	m.Outputs[0].Items = []interface{}{"a", "$this.("}
+	err = m.CompileScripts()
+	c.Assert(err, Not(IsNil))
+	c.Assert(err, ErrorMatches, "Couldn't parse expression in items field of variable 'url': .* in '\\$this.\\('")
+
+	m = newTypeCheckMetadata(c)
+	m.Stages["deploy"] = &ExecStage{Cmd: "$this.name.upper("}
+	err = m.CompileScripts()
+	c.Assert(err, Not(IsNil))
+	c.Assert(err, ErrorMatches, "Invalid stage 'deploy': Couldn't parse expression in exec stage: .* in '\\$this.name.upper\\('")
+
+	m = newTypeCheckMetadata(c)
+	m.Depends[0].Mapping["name"] = "$this.name.upper("
+	err = m.CompileScripts()
+	c.Assert(err, Not(IsNil))
+	c.Assert(err, ErrorMatches, "Invalid mapping for dependency '_/dep-v1.0': Couldn't parse expression for key 'name': .*")
+
+	m = newTypeCheckMetadata(c)
+	m.Templates[0].Mapping["replicas"] = "$this.name.upper("
+	err = m.CompileScripts()
+	c.Assert(err, Not(IsNil))
+	c.Assert(err, ErrorMatches, "Invalid mapping for template 'test.tpl': Couldn't parse expression for key 'replicas': .*")
+}
+
+func newBenchmarkMetadata(n int) (*ReleaseMetadata, *script.ScriptEnvironment) {
+	m := NewReleaseMetadata("test", "1.0")
+	inputs := map[string]script.Script{}
+	for i := 0; i < n; i++ {
+		id := "input" + strconv.Itoa(i)
+		inputs[id] = script.LiftString("projects/project/zones/europe-west1-b/clusters/" + id)
+		v, err := variables.NewVariableFromString(id+"_cluster", "string")
+		if err != nil {
+			panic(err)
+		}
+		v.Default = "$this.inputs." + id + ".split(\"/\")[1].concat(\"-\", $this.inputs." + id + ".split(\"/\")[5]).upper()"
+		m.AddOutputVariable(v)
+	}
+	env := script.NewScriptEnvironmentWithGlobals(map[string]script.Script{
+		"this": script.LiftDict(map[string]script.Script{
+			"inputs": script.LiftDict(inputs),
+		}),
+	})
+	return m, env
+}
+
+func benchmarkEvalOutputs(b *testing.B, precompile bool) {
+	m, env := newBenchmarkMetadata(1000)
+	script.DefaultScriptCache.Clear()
+	if precompile {
+		if err := m.CompileScripts(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !precompile {
+			script.DefaultScriptCache.Clear()
+		}
+		for _, output := range m.Outputs {
+			if _, err := output.GetValue(nil, env); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReleaseMetadata_eval_outputs(b *testing.B) {
+	benchmarkEvalOutputs(b, false)
+}
+
+func BenchmarkReleaseMetadata_eval_outputs_precompiled(b *testing.B) {
+	benchmarkEvalOutputs(b, true)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parsers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type SemanticVersion struct {
+	versionParts []string
+}
+
+func NewSemanticVersion(v string) *SemanticVersion {
+	return &SemanticVersion{
+		versionParts: strings.Split(v, "."),
+	}
+}
+
+// Parses a version consisting of one or more numeric parts, e.g. "1.2.3".
+func ParseSemanticVersion(v string) (*SemanticVersion, error) {
+	if !isSemanticVersion(strings.Split(v, ".")) {
+		return nil, InvalidVersionError(v)
+	}
+	return NewSemanticVersion(v), nil
+}
+
+func (s *SemanticVersion) OnlyKeepLeadingVersionPart() {
+	if len(s.versionParts) > 1 {
+		s.versionParts = s.versionParts[0:1]
+	}
+}
+
+func (s *SemanticVersion) IncrementSmallest() error {
+	lastIx := len(s.versionParts) - 1
+	last := s.versionParts[lastIx]
+	lastI, err := strconv.Atoi(last)
+	if err != nil {
+		return err
+	}
+	lastI += 1
+	s.versionParts[lastIx] = strconv.Itoa(lastI)
+	return nil
+}
+
+func (s *SemanticVersion) ToString() string {
+	return strings.Join(s.versionParts, ".")
+}
+
+func (s *SemanticVersion) Equals(o *SemanticVersion) bool {
+	return s.ToString() == o.ToString()
+}
+
+func (s *SemanticVersion) LessOrEqual(o *SemanticVersion) bool {
+	return s.Compare(o) <= 0
+}
+
+// Returns -1 if the version is less than o, 0 if they are equal and 1 if it's
+// greater. Missing parts count as 0, so "2" and "2.0.0" are equal. Parts that
+// are not numeric are less than numeric parts, and are compared as strings
+// with each other.
+func (s *SemanticVersion) Compare(o *SemanticVersion) int {
+	for ix := 0; ix < len(s.versionParts) || ix < len(o.versionParts); ix++ {
+		mine, theirs := s.partOrZero(ix), o.partOrZero(ix)
+		mineInt, mineIntErr := strconv.Atoi(mine)
+		theirsInt, theirsIntErr := strconv.Atoi(theirs)
+		if mineIntErr != nil && theirsIntErr != nil {
+			if cmp := strings.Compare(mine, theirs); cmp != 0 {
+				return cmp
+			}
+		} else if mineIntErr != nil {
+			return -1
+		} else if theirsIntErr != nil {
+			return 1
+		} else if mineInt < theirsInt {
+			return -1
+		} else if mineInt > theirsInt {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s *SemanticVersion) partOrZero(ix int) string {
+	if ix < len(s.versionParts) {
+		return s.versionParts[ix]
+	}
+	return "0"
+}
+
+// Returns the numeric version parts. Parts that are not numeric are returned
+// as 0, which can't happen for versions created by ParseSemanticVersion.
+func (s *SemanticVersion) Parts() []int {
+	result := []int{}
+	for _, part := range s.versionParts {
+		i, _ := strconv.Atoi(part)
+		result = append(result, i)
+	}
+	return result
+}
+
+// Increments the version part at index ix (0 for the major version) and
+// resets all the parts after it to 0. Missing parts are added, so
+// incrementing part 2 of "1" results in "1.0.1".
+func (s *SemanticVersion) IncrementPart(ix int) error {
+	if ix < 0 {
+		return fmt.Errorf("Invalid version part index '%d'", ix)
+	}
+	for len(s.versionParts) <= ix {
+		s.versionParts = append(s.versionParts, "0")
+	}
+	i, err := strconv.Atoi(s.versionParts[ix])
+	if err != nil {
+		return err
+	}
+	s.versionParts[ix] = strconv.Itoa(i + 1)
+	for j := ix + 1; j < len(s.versionParts); j++ {
+		s.versionParts[j] = "0"
+	}
+	return nil
+}
+
+var versionComparisons = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// Returns true if the version satisfies the query, which is a comma
+// separated list of constraints that must all hold. A constraint is either a
+// comparison (e.g. ">= 2", "< 1.5" or "!= 1.0.3") or a version query like the
+// ones used to reference releases (e.g. "1.2.@", "1.2.3" or "latest").
+func (s *SemanticVersion) Matches(query string) (bool, error) {
+	for _, constraint := range strings.Split(query, ",") {
+		ok, err := s.matchesConstraint(strings.TrimSpace(constraint))
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *SemanticVersion) matchesConstraint(constraint string) (bool, error) {
+	for _, op := range versionComparisons {
+		if !strings.HasPrefix(constraint, op) {
+			continue
+		}
+		other, err := ParseSemanticVersion(strings.TrimSpace(constraint[len(op):]))
+		if err != nil {
+			return false, fmt.Errorf("Invalid version constraint '%s'", constraint)
+		}
+		cmp := s.Compare(other)
+		switch op {
+		case ">=":
+			return cmp >= 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case ">":
+			return cmp > 0, nil
+		case "<":
+			return cmp < 0, nil
+		}
+		return cmp == 0, nil
+	}
+	vq, err := ParseVersionQuery(constraint)
+	if err != nil || vq.SpecificTag != "" {
+		return false, fmt.Errorf("Invalid version constraint '%s'", constraint)
+	}
+	if vq.LatestVersion {
+		return true, nil
+	}
+	if vq.VersionPrefix != "" {
+		return strings.HasPrefix(s.ToString()+".", vq.VersionPrefix), nil
+	}
+	return s.Compare(NewSemanticVersion(vq.SpecificVersion)) == 0, nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parsers
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type semverSuite struct{}
+
+var _ = Suite(&semverSuite{})
+
+func (s *semverSuite) Test_LessOrEqual(c *C) {
+	unit := NewSemanticVersion("0.0.3")
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.4.0")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.3.1")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.3.0")), Equals, true)
+	c.Assert(NewSemanticVersion("0.0.3.0").LessOrEqual(unit), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.1")), Equals, false)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.2")), Equals, false)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.3")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.4")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0.5")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.0")), Equals, false)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0.1")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("0")), Equals, false)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("1")), Equals, true)
+	c.Assert(unit.LessOrEqual(NewSemanticVersion("2")), Equals, true)
+}
+
+func (s *semverSuite) Test_IncrementSmallest(c *C) {
+	unit := NewSemanticVersion("0.0.3")
+	unit.IncrementSmallest()
+	c.Assert(unit.ToString(), Equals, "0.0.4")
+	unit = NewSemanticVersion("0.1")
+	unit.IncrementSmallest()
+	c.Assert(unit.ToString(), Equals, "0.2")
+	unit = NewSemanticVersion("0")
+	unit.IncrementSmallest()
+	c.Assert(unit.ToString(), Equals, "1")
+}
+
+func (s *semverSuite) Test_OnlyKeepLeadingVersionPart(c *C) {
+	unit := NewSemanticVersion("0.0.3")
+	unit.OnlyKeepLeadingVersionPart()
+	c.Assert(unit.ToString(), Equals, "0")
+	unit = NewSemanticVersion("10.23.33")
+	unit.OnlyKeepLeadingVersionPart()
+	c.Assert(unit.ToString(), Equals, "10")
+}
+
+func (s *semverSuite) Test_ParseSemanticVersion(c *C) {
+	for _, v := range []string{"0", "1.2", "1.2.3", "10.0.0.1"} {
+		version, err := ParseSemanticVersion(v)
+		c.Assert(err, IsNil)
+		c.Assert(version.ToString(), Equals, v)
+	}
+	for _, v := range []string{"", "1.", "1.a", "1.2.@", "v1.2", "latest", "-1"} {
+		_, err := ParseSemanticVersion(v)
+		c.Assert(err, Not(IsNil), Commentf("Version '%s'", v))
+		c.Assert(err.Error(), Equals, "Invalid version string '"+v+"'.")
+	}
+}
+
+func (s *semverSuite) Test_Compare(c *C) {
+	c.Assert(NewSemanticVersion("1.2.3").Compare(NewSemanticVersion("1.2.3")), Equals, 0)
+	c.Assert(NewSemanticVersion("1.2.3").Compare(NewSemanticVersion("1.10.0")), Equals, -1)
+	c.Assert(NewSemanticVersion("2").Compare(NewSemanticVersion("1.10.0")), Equals, 1)
+	c.Assert(NewSemanticVersion("1.0").Compare(NewSemanticVersion("1.0.0")), Equals, 0)
+	c.Assert(NewSemanticVersion("2.0.0").Compare(NewSemanticVersion("2")), Equals, 0)
+	c.Assert(NewSemanticVersion("2.0.1").Compare(NewSemanticVersion("2")), Equals, 1)
+	c.Assert(NewSemanticVersion("2").Compare(NewSemanticVersion("2.0.1")), Equals, -1)
+	c.Assert(NewSemanticVersion("1.a").Compare(NewSemanticVersion("1.0")), Equals, -1)
+	c.Assert(NewSemanticVersion("1.b").Compare(NewSemanticVersion("1.a")), Equals, 1)
+	c.Assert(NewSemanticVersion("1.a").Compare(NewSemanticVersion("1.a")), Equals, 0)
+}
+
+func (s *semverSuite) Test_Compare_agrees_with_LessOrEqual(c *C) {
+	versions := []string{
+		"0", "1", "2", "0.0", "0.1", "0.0.1", "0.0.2", "0.0.3", "0.0.4", "0.0.5",
+		"0.0.3.0", "0.0.3.1", "0.0.4.0", "1.0", "1.0.0", "1.2.3", "1.10.0",
+		"2.0.0", "2.0.1", "1.a", "1.b",
+	}
+	for _, a := range versions {
+		for _, b := range versions {
+			v1, v2 := NewSemanticVersion(a), NewSemanticVersion(b)
+			c.Assert(v1.Compare(v2) <= 0, Equals, v1.LessOrEqual(v2), Commentf("%s <= %s", a, b))
+			c.Assert(v1.Compare(v2), Equals, -v2.Compare(v1), Commentf("%s <=> %s", a, b))
+		}
+	}
+}
+
+func (s *semverSuite) Test_Parts(c *C) {
+	c.Assert(NewSemanticVersion("1.20.3").Parts(), DeepEquals, []int{1, 20, 3})
+	c.Assert(NewSemanticVersion("7").Parts(), DeepEquals, []int{7})
+}
+
+func (s *semverSuite) Test_IncrementPart(c *C) {
+	cases := []struct {
+		Version  string
+		Part     int
+		Expected string
+	}{
+		{"1.2.3", 0, "2.0.0"},
+		{"1.2.3", 1, "1.3.0"},
+		{"1.2.3", 2, "1.2.4"},
+		{"1", 2, "1.0.1"},
+		{"1.9", 0, "2.0"},
+	}
+	for _, test := range cases {
+		unit := NewSemanticVersion(test.Version)
+		c.Assert(unit.IncrementPart(test.Part), IsNil)
+		c.Assert(unit.ToString(), Equals, test.Expected)
+	}
+	c.Assert(NewSemanticVersion("1").IncrementPart(-1), Not(IsNil))
+}
+
+func (s *semverSuite) Test_Matches(c *C) {
+	cases := map[string]bool{
+		">= 2":        true,
+		">=2.1.0":     true,
+		">= 2.2":      false,
+		"> 2.1":       false,
+		"> 2.0.9":     true,
+		"< 3":         true,
+		"<= 2.1.0":    true,
+		"== 2.1.0":    true,
+		"= 2.1.0":     true,
+		"!= 2.1.0":    false,
+		">= 2, < 3":   true,
+		">= 2, < 2.1": false,
+		"2.1.0":       true,
+		"v2.1.0":      true,
+		"2.1.@":       true,
+		"2.@":         true,
+		"2.10.@":      false,
+		"1.@":         false,
+		"latest":      true,
+		"@, >= 2":     true,
+	}
+	unit := NewSemanticVersion("2.1.0")
+	for query, expected := range cases {
+		ok, err := unit.Matches(query)
+		c.Assert(err, IsNil, Commentf("Query '%s'", query))
+		c.Assert(ok, Equals, expected, Commentf("Query '%s'", query))
+	}
+	for _, query := range []string{"", ">=", "> a", "my-tag", ">= 2,"} {
+		_, err := unit.Matches(query)
+		c.Assert(err, Not(IsNil), Commentf("Query '%s'", query))
+	}
+}
+
+func (s *semverSuite) Test_Matches_pads_missing_parts_with_zero(c *C) {
+	cases := map[string]bool{
+		"<= 2":  true,
+		"== 2":  true,
+		">= 2":  true,
+		"> 2":   false,
+		"< 2":   false,
+		"2":     true,
+		"2.0":   true,
+		"!= 2":  false,
+		"< 2.1": true,
+	}
+	unit := NewSemanticVersion("2.0.0")
+	for query, expected := range cases {
+		ok, err := unit.Matches(query)
+		c.Assert(err, IsNil, Commentf("Query '%s'", query))
+		c.Assert(ok, Equals, expected, Commentf("Query '%s'", query))
+	}
+}
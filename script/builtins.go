@@ -20,11 +20,11 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ankyra/escape-core/util"
 )
@@ -34,7 +34,15 @@ type StdlibFunc struct {
 	Func   Script
 	Doc    string
 	ActsOn string
-	Args   string
+
+	// The full parameter list, including the value the function acts on when
+	// it's called as a method, e.g. "lst :: list, sep :: string". Optional
+	// parameters are written between brackets and "..." repeats the previous
+	// parameter. Used to generate the documentation and to type check scripts.
+	Args string
+
+	// The type of the returned value, or "*" if it depends on the arguments.
+	Returns string
 }
 
 var trackMajorVersion = ShouldParse(`$func(v) { $v.split(".")[:1].join(".").concat(".@") }`)
@@ -43,39 +51,99 @@ var trackPatchVersion = ShouldParse(`$func(v) { $v.split(".")[:3].join(".").conc
 var trackVersion = ShouldParse(`$func(v) { $v.concat(".@") }`)
 
 var Stdlib = []StdlibFunc{
-	StdlibFunc{"id", LiftFunction(builtinId), "Returns its argument", "everything", "parameter :: *"},
-	StdlibFunc{"equals", LiftFunction(builtinEquals), "Returns true if the arguments are of the same type and have the same value", "everything", "parameter :: *"},
-	StdlibFunc{"env_lookup", LiftFunction(builtinEnvLookup), "Lookup key in environment. Usually called implicitly when using '$'", "lists", "key :: string"},
-	StdlibFunc{"concat", LiftFunction(builtinConcat), "Concatate stringable arguments", "strings", "v1 :: string, v2 :: string, ..."},
-	StdlibFunc{"lower", ShouldLift(strings.ToLower), "Returns a copy of the string v with all Unicode characters mapped to their lower case", "strings", "v :: string"},
-	StdlibFunc{"upper", ShouldLift(strings.ToUpper), "Returns a copy of the string v with all Unicode characters mapped to their upper case", "strings", "v :: string"},
-	StdlibFunc{"title", ShouldLift(strings.ToTitle), "Returns a copy of the string v with all Unicode characters mapped to their title case", "strings", "v :: string"},
-	StdlibFunc{"split", ShouldLift(strings.Split), "Split slices s into all substrings separated by sep and returns a slice of the substrings between those separators. If sep is empty, Split splits after each UTF-8 sequence.", "strings", "sep :: string"},
-	StdlibFunc{"path_exists", ShouldLift(util.PathExists), "Returns true if the path exists, false if not", "strings", ""},
-	StdlibFunc{"file_exists", ShouldLift(util.FileExists), "Returns true if the path exists and if it's not a directory, false otherwise", "strings", ""},
-	StdlibFunc{"dir_exists", ShouldLift(util.IsDir), "Returns true if the path exists and if it is a directory, false otherwise", "strings", ""},
-	StdlibFunc{"join", ShouldLift(strings.Join), "Join concatenates the elements of a to create a single string. The separator string sep is placed between elements in the resulting string. ", "lists", "sep :: string"},
-	StdlibFunc{"replace", ShouldLift(strings.Replace), "Replace returns a copy of the string s with the first n non-overlapping instances of old replaced by new. If old is empty, it matches at the beginning of the string and after each UTF-8 sequence, yielding up to k+1 replacements for a k-rune string. If n < 0, there is no limit on the number of replacements.", "strings", "old :: string, new :: string, n :: integer"},
-	StdlibFunc{"base64_encode", ShouldLift(base64.StdEncoding.EncodeToString), "Encode string to base64", "strings", ""},
-	StdlibFunc{"base64_decode", ShouldLift(base64.StdEncoding.DecodeString), "Decode string from base64", "strings", ""},
-	StdlibFunc{"trim", ShouldLift(strings.TrimSpace), "Returns a slice of the string s, with all leading and trailing white space removed, as defined by Unicode. ", "strings", ""},
-	StdlibFunc{"list_index", LiftFunction(builtinListIndex), "Index a list at position `n`. Usually accessed implicitly using indexing syntax (eg. `list[0]`)", "lists", "n :: integer"},
-	StdlibFunc{"length", LiftFunction(builtinListLength), "Returns the length of the list", "lists", "n :: integer"},
-	StdlibFunc{"list_slice", LiftFunction(builtinListSlice), "Slice a list. Usually accessed implicitly using slice syntax (eg. `list[0:5]`)", "lists", "i :: integer, j :: integer"},
-	StdlibFunc{"add", ShouldLift(builtinAdd), "Add two integers", "integers", "y :: integer"},
-	StdlibFunc{"timestamp", ShouldLift(builtinTimestamp), "Returns a UNIX timestamp", "", ""},
-	StdlibFunc{"read_file", ShouldLift(builtinReadfile), "Read the contents of a file", "strings", ""},
-	StdlibFunc{"track_major_version", trackMajorVersion, "Track major version", "strings", ""},
-	StdlibFunc{"track_minor_version", trackMinorVersion, "Track minor version", "strings", ""},
-	StdlibFunc{"track_patch_version", trackPatchVersion, "Track patch version", "strings", ""},
-	StdlibFunc{"track_version", trackVersion, "Track version", "strings", ""},
-	StdlibFunc{"not", LiftFunction(builtinNOT), "Logical NOT operation", "bool", ""},
-	StdlibFunc{"and", LiftFunction(builtinAND), "Logical AND operation", "bool", "b2 :: bool"},
-	StdlibFunc{"or", LiftFunction(builtinOR), "Logical OR operation", "bool", "b2 :: bool"},
-	StdlibFunc{"lt", LiftFunction(builtinLT), "Returns true if first argument is less than the second argument", "integer", "i2 :: integer"},
-	StdlibFunc{"lte", LiftFunction(builtinLTE), "Returns true if first argument is less than or equal to the second argument", "integer", "i2 :: integer"},
-	StdlibFunc{"gt", LiftFunction(builtinGT), "Returns true if first argument is greater than second argument", "integer", "i2 :: integer"},
-	StdlibFunc{"gte", LiftFunction(builtinGTE), "Returns true if first argument is greater than or equal to second argument", "integer", "i2 :: integer"},
+	StdlibFunc{"id", LiftFunction(builtinId), "Returns its argument", "everything", "v :: *", "*"},
+	StdlibFunc{"default", LiftFunction(builtinDefault), "Returns v, or fallback if v is null (e.g. the result of a safe navigation like $p?.outputs?.url that didn't find anything). Unlike the '??' operator, the fallback is always evaluated", "everything", "v :: *, fallback :: *", "*"},
+	StdlibFunc{"error", LiftFunction(builtinError), "Fails the evaluation with the given message. Can be used to validate values, e.g. $if($n > 0, $n, $__error(\"n should be positive\")). Use 'try' to recover from errors", "strings", "message :: string", "*"},
+	StdlibFunc{"equals", LiftFunction(builtinEquals), "Returns true if the arguments are of the same type and have the same value. Integers and floats are compared by value, so 1 equals 1.0", "everything", "v1 :: *, v2 :: *", "bool"},
+	StdlibFunc{"env_lookup", LiftFunction(builtinEnvLookup), "Lookup key in environment. Usually called implicitly when using '$'", "lists", "key :: string", "*"},
+	StdlibFunc{"concat", LiftFunction(builtinConcat), "Concatate stringable arguments", "strings", "v :: string|integer|float, ...", "string"},
+	StdlibFunc{"lower", ShouldLift(strings.ToLower), "Returns a copy of the string v with all Unicode characters mapped to their lower case", "strings", "v :: string", "string"},
+	StdlibFunc{"upper", ShouldLift(strings.ToUpper), "Returns a copy of the string v with all Unicode characters mapped to their upper case", "strings", "v :: string", "string"},
+	StdlibFunc{"title", LiftFunction(builtinTitle), "Returns a copy of the string v with the first letter of every word in title case and the other letters in lower case, e.g. 'hello WORLD' becomes 'Hello World'", "strings", "v :: string", "string"},
+	StdlibFunc{"has_prefix", ShouldLift(strings.HasPrefix), "Returns true if the string v begins with prefix", "strings", "v :: string, prefix :: string", "bool"},
+	StdlibFunc{"has_suffix", ShouldLift(strings.HasSuffix), "Returns true if the string v ends with suffix", "strings", "v :: string, suffix :: string", "bool"},
+	StdlibFunc{"contains", ShouldLift(strings.Contains), "Returns true if substr is within the string v", "strings", "v :: string, substr :: string", "bool"},
+	StdlibFunc{"index_of", LiftFunction(builtinIndexOf), "Returns the index (in characters) of the first occurrence of substr in the string v, or -1 if substr is not present", "strings", "v :: string, substr :: string", "integer"},
+	StdlibFunc{"substring", LiftFunction(builtinSubstring), "Returns the characters of v from index i up to, but not including, index j (or the end of the string). Negative indices count from the end of the string", "strings", "v :: string, i :: integer, [j :: integer]", "string"},
+	StdlibFunc{"pad_left", LiftFunction(builtinPad("pad_left", true)), "Pads the string v on the left to a length of n characters, using spaces or the given padding character", "strings", "v :: string, n :: integer, [padding :: string]", "string"},
+	StdlibFunc{"pad_right", LiftFunction(builtinPad("pad_right", false)), "Pads the string v on the right to a length of n characters, using spaces or the given padding character", "strings", "v :: string, n :: integer, [padding :: string]", "string"},
+	StdlibFunc{"repeat", LiftFunction(builtinRepeat), "Returns a new string consisting of count copies of the string v", "strings", "v :: string, count :: integer", "string"},
+	StdlibFunc{"trim_prefix", ShouldLift(strings.TrimPrefix), "Returns v without the leading prefix. If v doesn't start with prefix, v is returned unchanged", "strings", "v :: string, prefix :: string", "string"},
+	StdlibFunc{"trim_suffix", ShouldLift(strings.TrimSuffix), "Returns v without the trailing suffix. If v doesn't end with suffix, v is returned unchanged", "strings", "v :: string, suffix :: string", "string"},
+	StdlibFunc{"format", LiftFunction(builtinFormat), "Formats the arguments according to the format string, e.g. $__format(\"%s:%d\", $host, $port). Supports Go's formatting verbs, flags, width and precision (e.g. '%-10s', '%05d' or '%.2f'). Fails if the arguments don't match the verbs", "strings", "fmt :: string, v :: *, ...", "string"},
+	StdlibFunc{"split", ShouldLift(strings.Split), "Split slices s into all substrings separated by sep and returns a slice of the substrings between those separators. If sep is empty, Split splits after each UTF-8 sequence.", "strings", "v :: string, sep :: string", "list"},
+	StdlibFunc{"path_exists", LiftFunction(builtinPathExists), "Returns true if the path exists, false if not", "strings", "path :: string", "bool"},
+	StdlibFunc{"file_exists", LiftFunction(builtinFileExists), "Returns true if the path exists and if it's not a directory, false otherwise", "strings", "path :: string", "bool"},
+	StdlibFunc{"dir_exists", LiftFunction(builtinDirExists), "Returns true if the path exists and if it is a directory, false otherwise", "strings", "path :: string", "bool"},
+	StdlibFunc{"join", ShouldLift(strings.Join), "Join concatenates the elements of a to create a single string. The separator string sep is placed between elements in the resulting string. ", "lists", "lst :: list, sep :: string", "string"},
+	StdlibFunc{"replace", ShouldLift(strings.Replace), "Replace returns a copy of the string s with the first n non-overlapping instances of old replaced by new. If old is empty, it matches at the beginning of the string and after each UTF-8 sequence, yielding up to k+1 replacements for a k-rune string. If n < 0, there is no limit on the number of replacements.", "strings", "v :: string, old :: string, new :: string, n :: integer", "string"},
+	StdlibFunc{"base64_encode", ShouldLift(base64.StdEncoding.EncodeToString), "Encode string to base64", "strings", "v :: string", "string"},
+	StdlibFunc{"base64_decode", ShouldLift(base64.StdEncoding.DecodeString), "Decode string from base64", "strings", "v :: string", "string"},
+	StdlibFunc{"trim", ShouldLift(strings.TrimSpace), "Returns a slice of the string s, with all leading and trailing white space removed, as defined by Unicode. ", "strings", "v :: string", "string"},
+	StdlibFunc{"list_index", LiftFunction(builtinListIndex), "Index a list at position `n`. Usually accessed implicitly using indexing syntax (eg. `list[0]`)", "lists", "lst :: list, n :: integer", "*"},
+	StdlibFunc{"length", LiftFunction(builtinListLength), "Returns the length of the list", "lists", "v :: list|string", "integer"},
+	StdlibFunc{"list_slice", LiftFunction(builtinListSlice), "Slice a list. Usually accessed implicitly using slice syntax (eg. `list[0:5]`)", "lists", "lst :: list, i :: integer, [j :: integer]", "list"},
+	StdlibFunc{"add", LiftFunction(builtinAdd), "Add two numbers. The result is a float if either of the arguments is a float", "integers", "x :: integer|float, y :: integer|float", "integer|float"},
+	StdlibFunc{"subtract", LiftFunction(builtinSubtract), "Subtract two numbers. The result is a float if either of the arguments is a float", "integers", "x :: integer|float, y :: integer|float", "integer|float"},
+	StdlibFunc{"multiply", LiftFunction(builtinMultiply), "Multiply two numbers. The result is a float if either of the arguments is a float", "integers", "x :: integer|float, y :: integer|float", "integer|float"},
+	StdlibFunc{"divide", LiftFunction(builtinDivide), "Divide two numbers. Dividing two integers results in an integer (rounded towards zero), otherwise the result is a float. Fails when dividing by zero", "integers", "x :: integer|float, y :: integer|float", "integer|float"},
+	StdlibFunc{"modulo", LiftFunction(builtinModulo), "Returns the remainder of dividing two numbers. The result is a float if either of the arguments is a float. Fails when dividing by zero", "integers", "x :: integer|float, y :: integer|float", "integer|float"},
+	StdlibFunc{"timestamp", LiftFunction(builtinTimestamp), "Returns a UNIX timestamp", "", "", "string"},
+	StdlibFunc{"now", LiftFunction(builtinNow), "Returns the current time as an RFC 3339 string in UTC (e.g. '2017-07-14T02:40:00Z'). The clock can be fixed using the evaluation policy", "", "", "string"},
+	StdlibFunc{"format_time", LiftFunction(builtinFormatTime), "Formats the time t (an RFC 3339 string or a UNIX timestamp) using the layout, which is either the name of a standard layout (e.g. 'RFC1123' or 'DateOnly') or a Go layout string (e.g. '2006-01-02 15:04'). The time is formatted in the time zone tz (e.g. 'Europe/Amsterdam') if given, and in UTC otherwise", "strings", "t :: string|integer, layout :: string, [tz :: string]", "string"},
+	StdlibFunc{"parse_time", LiftFunction(builtinParseTime), "Parses the string v using the layout (see format_time) and returns the time as an RFC 3339 string in UTC. If the layout doesn't contain a time zone, the time is interpreted in the time zone tz if given, and in UTC otherwise", "strings", "v :: string, layout :: string, [tz :: string]", "string"},
+	StdlibFunc{"add_duration", LiftFunction(builtinAddDuration), "Adds the duration d (e.g. '1h30m', '-15m' or '90d') to the time t and returns the result as an RFC 3339 string in UTC", "strings", "t :: string|integer, d :: string", "string"},
+	StdlibFunc{"duration_seconds", LiftFunction(builtinDurationSeconds), "Returns the number of seconds in the duration d (e.g. '1h30m' or '90d')", "strings", "d :: string", "integer"},
+	StdlibFunc{"read_file", LiftFunction(builtinReadfile), "Read the contents of a file", "strings", "path :: string", "string"},
+	StdlibFunc{"track_major_version", trackMajorVersion, "Track major version", "strings", "v :: string", "string"},
+	StdlibFunc{"track_minor_version", trackMinorVersion, "Track minor version", "strings", "v :: string", "string"},
+	StdlibFunc{"track_patch_version", trackPatchVersion, "Track patch version", "strings", "v :: string", "string"},
+	StdlibFunc{"track_version", trackVersion, "Track version", "strings", "v :: string", "string"},
+	StdlibFunc{"version_compare", LiftFunction(builtinVersionCompare), "Compares two versions (e.g. '1.2.3') part by part, where missing parts count as 0, and returns -1 if v1 is lower than v2, 0 if they're equal and 1 if v1 is higher", "strings", "v1 :: string, v2 :: string", "integer"},
+	StdlibFunc{"version_gt", LiftFunction(builtinVersionGT), "Returns true if version v1 is higher than version v2", "strings", "v1 :: string, v2 :: string", "bool"},
+	StdlibFunc{"version_parts", LiftFunction(builtinVersionParts), "Returns the parts of the version as a list of integers, e.g. [1, 2, 3] for '1.2.3'", "strings", "v :: string", "list"},
+	StdlibFunc{"bump_major", LiftFunction(builtinBumpVersion("bump_major", 0)), "Increments the major version and resets the other parts to 0, e.g. '1.2.3' becomes '2.0.0'", "strings", "v :: string", "string"},
+	StdlibFunc{"bump_minor", LiftFunction(builtinBumpVersion("bump_minor", 1)), "Increments the minor version and resets the patch version to 0, e.g. '1.2.3' becomes '1.3.0'", "strings", "v :: string", "string"},
+	StdlibFunc{"bump_patch", LiftFunction(builtinBumpVersion("bump_patch", 2)), "Increments the patch version, e.g. '1.2.3' becomes '1.2.4'", "strings", "v :: string", "string"},
+	StdlibFunc{"version_matches", LiftFunction(builtinVersionMatches), "Returns true if the version matches the query. The query is a comma separated list of constraints that must all hold, where a constraint is either a comparison (e.g. '>= 2' or '< 1.5') or a version query like '1.2.@', '1.2.3' or 'latest'", "strings", "v :: string, query :: string", "bool"},
+	StdlibFunc{"not", LiftFunction(builtinNOT), "Logical NOT operation", "bool", "b :: bool", "bool"},
+	StdlibFunc{"and", LiftFunction(builtinAND), "Logical AND operation", "bool", "b1 :: bool, b2 :: bool", "bool"},
+	StdlibFunc{"or", LiftFunction(builtinOR), "Logical OR operation", "bool", "b1 :: bool, b2 :: bool", "bool"},
+	StdlibFunc{"lt", LiftFunction(builtinLT), "Returns true if first argument is less than the second argument", "integer", "i1 :: integer|float, i2 :: integer|float", "bool"},
+	StdlibFunc{"lte", LiftFunction(builtinLTE), "Returns true if first argument is less than or equal to the second argument", "integer", "i1 :: integer|float, i2 :: integer|float", "bool"},
+	StdlibFunc{"gt", LiftFunction(builtinGT), "Returns true if first argument is greater than second argument", "integer", "i1 :: integer|float, i2 :: integer|float", "bool"},
+	StdlibFunc{"gte", LiftFunction(builtinGTE), "Returns true if first argument is greater than or equal to second argument", "integer", "i1 :: integer|float, i2 :: integer|float", "bool"},
+	StdlibFunc{"map", LiftFunction(builtinMap), "Returns a new list with the function f applied to each item", "lists", "lst :: list, f :: func", "list"},
+	StdlibFunc{"filter", LiftFunction(builtinFilter), "Returns a new list containing only the items for which f returns true", "lists", "lst :: list, f :: func", "list"},
+	StdlibFunc{"reduce", LiftFunction(builtinReduce), "Combines the items in the list, starting with initial, by calling f(accumulator, item) for each item", "lists", "lst :: list, f :: func, initial :: *", "*"},
+	StdlibFunc{"any", LiftFunction(builtinAny), "Returns true if f returns true for at least one item in the list", "lists", "lst :: list, f :: func", "bool"},
+	StdlibFunc{"all", LiftFunction(builtinAll), "Returns true if f returns true for every item in the list", "lists", "lst :: list, f :: func", "bool"},
+	StdlibFunc{"sort_by", LiftFunction(builtinSortBy), "Returns a new list sorted by the (integer or string) key that f returns for each item. The sort is stable", "lists", "lst :: list, f :: func", "list"},
+	StdlibFunc{"find", LiftFunction(builtinFind), "Returns the first item for which f returns true. Returns default, if given, when no item matches; fails otherwise", "lists", "lst :: list, f :: func, [default :: *]", "*"},
+	StdlibFunc{"matches", LiftFunction(builtinMatches), "Returns true if the string v contains a match of the regular expression pattern. Use '^' and '$' to match the whole string", "strings", "v :: string, pattern :: string", "bool"},
+	StdlibFunc{"regex_find", LiftFunction(builtinRegexFind), "Returns the leftmost match of the regular expression pattern in v, or an empty string if there is no match. If the pattern contains a capture group, the text matched by the first group is returned instead", "strings", "v :: string, pattern :: string", "string"},
+	StdlibFunc{"regex_find_all", LiftFunction(builtinRegexFindAll), "Returns all the non-overlapping matches of the regular expression pattern in v. If the pattern contains a capture group, the text matched by the first group is returned for every match", "strings", "v :: string, pattern :: string", "list"},
+	StdlibFunc{"regex_replace", LiftFunction(builtinRegexReplace), "Replaces all the matches of the regular expression pattern in v with repl. Inside repl, '$1' or '${1}' refers to the text matched by the first capture group", "strings", "v :: string, pattern :: string, repl :: string", "string"},
+	StdlibFunc{"regex_split", LiftFunction(builtinRegexSplit), "Splits v into the substrings between the matches of the regular expression pattern", "strings", "v :: string, pattern :: string", "list"},
+	StdlibFunc{"to_json", LiftFunction(builtinToJson), "Encodes the value as JSON. Dictionary keys are sorted. Fails if the value contains functions", "everything", "v :: *", "string"},
+	StdlibFunc{"from_json", LiftFunction(builtinFromJson), "Decodes a JSON string into a value. Objects become dictionaries and arrays become lists", "strings", "v :: string", "*"},
+	StdlibFunc{"to_yaml", LiftFunction(builtinToYaml), "Encodes the value as YAML. Dictionary keys are sorted. Fails if the value contains functions", "everything", "v :: *", "string"},
+	StdlibFunc{"from_yaml", LiftFunction(builtinFromYaml), "Decodes a YAML string into a value. Mappings become dictionaries and sequences become lists", "strings", "v :: string", "*"},
+	StdlibFunc{"sha256", LiftFunction(builtinSha256), "Returns the hex encoded SHA-256 checksum of the string", "strings", "v :: string", "string"},
+	StdlibFunc{"sha1", LiftFunction(builtinSha1), "Returns the hex encoded SHA-1 checksum of the string", "strings", "v :: string", "string"},
+	StdlibFunc{"md5", LiftFunction(builtinMd5), "Returns the hex encoded MD5 checksum of the string", "strings", "v :: string", "string"},
+	StdlibFunc{"hmac_sha256", LiftFunction(builtinHmacSha256), "Returns the hex encoded HMAC-SHA256 of the string, using key as the secret", "strings", "v :: string, key :: string", "string"},
+	StdlibFunc{"uuid_v4", LiftFunction(builtinUUIDv4), "Returns a new random (version 4) UUID", "", "", "string"},
+	StdlibFunc{"uuid_v5", LiftFunction(builtinUUIDv5), "Returns the name based (version 5) UUID for name in the namespace. The namespace is a UUID or one of 'dns', 'url', 'oid' and 'x500'", "strings", "namespace :: string, name :: string", "string"},
+	StdlibFunc{"random_string", LiftFunction(builtinRandomString), "Returns a random alphanumeric string of length n. If a seed is given the string is derived from the seed and the current project, environment and deployment, so that it stays the same on every deploy", "integers", "n :: integer, [seed :: string]", "string"},
+	StdlibFunc{"password", LiftFunction(builtinPassword), "Returns a random password of length n (at least 4), containing lower and upper case letters, digits and symbols. If a seed is given the password is derived from the seed and the current project, environment and deployment, so that it stays the same on every deploy", "integers", "n :: integer, [seed :: string]", "string"},
+	StdlibFunc{"keys", LiftFunction(builtinKeys), "Returns the sorted keys of the dictionary", "dicts", "d :: map", "list"},
+	StdlibFunc{"values", LiftFunction(builtinValues), "Returns the values of the dictionary, ordered by their keys", "dicts", "d :: map", "list"},
+	StdlibFunc{"items", LiftFunction(builtinItems), "Returns a list of dictionaries with a 'key' and a 'value' field for every item in the dictionary, ordered by key", "dicts", "d :: map", "list"},
+	StdlibFunc{"has_key", LiftFunction(builtinHasKey), "Returns true if the dictionary contains the key", "dicts", "d :: map, key :: string", "bool"},
+	StdlibFunc{"get", LiftFunction(builtinGet), "Returns the value for key in the dictionary. Returns default, if given, when the key is missing; fails otherwise", "dicts", "d :: map, key :: string, [default :: *]", "*"},
+	StdlibFunc{"merge", LiftFunction(builtinMerge), "Returns a new dictionary containing the items of all the given dictionaries. When a key occurs more than once, the value of the last dictionary wins", "dicts", "d :: map, ...", "map"},
+	StdlibFunc{"without", LiftFunction(builtinWithout), "Returns a copy of the dictionary without the given keys", "dicts", "d :: map, key :: string, ...", "map"},
 }
 
 func LiftGoFunc(f interface{}) Script {
@@ -170,6 +238,27 @@ func builtinId(env *ScriptEnvironment, inputValues []Script) (Script, error) {
 	return inputValues[0], nil
 }
 
+func builtinDefault(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "default", inputValues); err != nil {
+		return nil, err
+	}
+	if IsNullAtom(inputValues[0]) {
+		return inputValues[1], nil
+	}
+	return inputValues[0], nil
+}
+
+func builtinError(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "error", inputValues); err != nil {
+		return nil, err
+	}
+	arg := inputValues[0]
+	if !IsStringAtom(arg) {
+		return nil, fmt.Errorf("Expecting string argument in error call, but got '%s'", arg.Type().Name())
+	}
+	return nil, fmt.Errorf("%s", ExpectStringAtom(arg))
+}
+
 func builtinEnvLookup(env *ScriptEnvironment, inputValues []Script) (Script, error) {
 	if err := builtinArgCheck(1, "env_lookup", inputValues); err != nil {
 		return nil, err
@@ -180,7 +269,7 @@ func builtinEnvLookup(env *ScriptEnvironment, inputValues []Script) (Script, err
 	}
 	key := ExpectStringAtom(arg)
 	val, ok := (*env)[key]
-	if !ok {
+	if !ok || isReservedEnvironmentKey(key) {
 		return nil, fmt.Errorf("Field '%s' was not found in environment.", key)
 	}
 	return val, nil
@@ -193,6 +282,8 @@ func builtinConcat(env *ScriptEnvironment, inputValues []Script) (Script, error)
 			result += ExpectStringAtom(val)
 		} else if IsIntegerAtom(val) {
 			result += strconv.Itoa(ExpectIntegerAtom(val))
+		} else if IsFloatAtom(val) {
+			result += FormatFloat(ExpectFloatAtom(val))
 		} else {
 			return nil, fmt.Errorf("Can't concatenate value of type %s", val.Type().Name())
 		}
@@ -251,6 +342,9 @@ func builtinListSlice(env *ScriptEnvironment, inputValues []Script) (Script, err
 	}
 	lst := ExpectListAtom(inputValues[0])
 	index := ExpectIntegerAtom(inputValues[1])
+	if index < 0 || index > len(lst) {
+		return nil, fmt.Errorf("Index '%d' out of range in list slice call (len: %d)", index, len(lst))
+	}
 
 	if len(inputValues) == 3 {
 		endSliceArg := inputValues[2]
@@ -261,6 +355,12 @@ func builtinListSlice(env *ScriptEnvironment, inputValues []Script) (Script, err
 		if endIndex < 0 {
 			endIndex = len(lst) + endIndex
 		}
+		if endIndex < 0 || endIndex > len(lst) {
+			return nil, fmt.Errorf("Index '%d' out of range in list slice call (len: %d)", ExpectIntegerAtom(inputValues[2]), len(lst))
+		}
+		if index > endIndex {
+			return nil, fmt.Errorf("Start index '%d' is greater than end index '%d' in list slice call", index, endIndex)
+		}
 		return Lift(lst[index:endIndex])
 	}
 	return Lift(lst[index:])
@@ -272,6 +372,9 @@ func builtinEquals(env *ScriptEnvironment, inputValues []Script) (Script, error)
 	}
 	i1 := inputValues[0]
 	i2 := inputValues[1]
+	if isNumber(i1) && isNumber(i2) {
+		return Lift(expectNumber(i1) == expectNumber(i2))
+	}
 	return Lift(i1.Equals(i2))
 }
 
@@ -315,74 +418,174 @@ func builtinOR(env *ScriptEnvironment, inputValues []Script) (Script, error) {
 	return Lift(bool1 || bool2)
 }
 
-func builtinLT(env *ScriptEnvironment, inputValues []Script) (Script, error) {
-	if err := builtinArgCheck(2, "lt", inputValues); err != nil {
-		return nil, err
+func builtinNumericArgs(funcName string, inputValues []Script) (Script, Script, error) {
+	if err := builtinArgCheck(2, funcName, inputValues); err != nil {
+		return nil, nil, err
 	}
 	arg1 := inputValues[0]
 	arg2 := inputValues[1]
-	if !IsIntegerAtom(arg1) || !IsIntegerAtom(arg2) {
-		return nil, fmt.Errorf("Expecting integer arguments in lt call, but got '%s' and '%s'", arg1.Type().Name(), arg2.Type().Name())
+	if !isNumber(arg1) || !isNumber(arg2) {
+		return nil, nil, fmt.Errorf("Expecting integer or float arguments in %s call, but got '%s' and '%s'", funcName, arg1.Type().Name(), arg2.Type().Name())
 	}
-	i1 := ExpectIntegerAtom(arg1)
-	i2 := ExpectIntegerAtom(arg2)
-	return Lift(i1 < i2)
+	return arg1, arg2, nil
 }
 
-func builtinLTE(env *ScriptEnvironment, inputValues []Script) (Script, error) {
-	if err := builtinArgCheck(2, "lte", inputValues); err != nil {
-		return nil, err
+func isNumber(s Script) bool {
+	return IsIntegerAtom(s) || IsFloatAtom(s)
+}
+
+func expectNumber(s Script) float64 {
+	if IsIntegerAtom(s) {
+		return float64(ExpectIntegerAtom(s))
 	}
-	arg1 := inputValues[0]
-	arg2 := inputValues[1]
-	if !IsIntegerAtom(arg1) || !IsIntegerAtom(arg2) {
-		return nil, fmt.Errorf("Expecting integer arguments in lte call, but got '%s' and '%s'", arg1.Type().Name(), arg2.Type().Name())
+	return ExpectFloatAtom(s)
+}
+
+// Integers are compared as integers, so that precision isn't lost for large
+// values. Mixed arguments are compared as floats.
+func builtinComparison(funcName string, cmp func(x, y float64) bool, intCmp func(x, y int) bool) scriptFuncType {
+	return func(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+		arg1, arg2, err := builtinNumericArgs(funcName, inputValues)
+		if err != nil {
+			return nil, err
+		}
+		if IsIntegerAtom(arg1) && IsIntegerAtom(arg2) {
+			return Lift(intCmp(ExpectIntegerAtom(arg1), ExpectIntegerAtom(arg2)))
+		}
+		return Lift(cmp(expectNumber(arg1), expectNumber(arg2)))
+	}
+}
+
+var builtinLT = builtinComparison("lt",
+	func(x, y float64) bool { return x < y },
+	func(x, y int) bool { return x < y })
+var builtinLTE = builtinComparison("lte",
+	func(x, y float64) bool { return x <= y },
+	func(x, y int) bool { return x <= y })
+var builtinGT = builtinComparison("gt",
+	func(x, y float64) bool { return x > y },
+	func(x, y int) bool { return x > y })
+var builtinGTE = builtinComparison("gte",
+	func(x, y float64) bool { return x >= y },
+	func(x, y int) bool { return x >= y })
+
+func builtinPathArg(funcName string, env *ScriptEnvironment, inputValues []Script) (string, error) {
+	if err := builtinArgCheck(1, funcName, inputValues); err != nil {
+		return "", err
+	}
+	arg := inputValues[0]
+	if !IsStringAtom(arg) {
+		return "", fmt.Errorf("Expecting string argument in %s call, but got '%s'", funcName, arg.Type().Name())
 	}
-	i1 := ExpectIntegerAtom(arg1)
-	i2 := ExpectIntegerAtom(arg2)
-	return Lift(i1 <= i2)
+	return env.GetEvalPolicy().ResolvePath(funcName, ExpectStringAtom(arg))
 }
 
-func builtinGT(env *ScriptEnvironment, inputValues []Script) (Script, error) {
-	if err := builtinArgCheck(2, "gt", inputValues); err != nil {
+func builtinReadfile(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	path, err := builtinPathArg("read_file", env, inputValues)
+	if err != nil {
 		return nil, err
 	}
-	arg1 := inputValues[0]
-	arg2 := inputValues[1]
-	if !IsIntegerAtom(arg1) || !IsIntegerAtom(arg2) {
-		return nil, fmt.Errorf("Expecting integer arguments in gt call, but got '%s' and '%s'", arg1.Type().Name(), arg2.Type().Name())
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
-	i1 := ExpectIntegerAtom(arg1)
-	i2 := ExpectIntegerAtom(arg2)
-	return Lift(i1 > i2)
+	return LiftString(string(bytes)), nil
 }
 
-func builtinGTE(env *ScriptEnvironment, inputValues []Script) (Script, error) {
-	if err := builtinArgCheck(2, "gte", inputValues); err != nil {
+func builtinPathExists(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	path, err := builtinPathArg("path_exists", env, inputValues)
+	if err != nil {
 		return nil, err
 	}
-	arg1 := inputValues[0]
-	arg2 := inputValues[1]
-	if !IsIntegerAtom(arg1) || !IsIntegerAtom(arg2) {
-		return nil, fmt.Errorf("Expecting integer arguments in gte call, but got '%s' and '%s'", arg1.Type().Name(), arg2.Type().Name())
+	return LiftBool(util.PathExists(path)), nil
+}
+
+func builtinFileExists(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	path, err := builtinPathArg("file_exists", env, inputValues)
+	if err != nil {
+		return nil, err
 	}
-	i1 := ExpectIntegerAtom(arg1)
-	i2 := ExpectIntegerAtom(arg2)
-	return Lift(i1 >= i2)
+	return LiftBool(util.FileExists(path)), nil
 }
 
-func builtinReadfile(arg string) (string, error) {
-	bytes, err := ioutil.ReadFile(arg)
+func builtinDirExists(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	path, err := builtinPathArg("dir_exists", env, inputValues)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(bytes), nil
+	return LiftBool(util.IsDir(path)), nil
 }
 
-func builtinTimestamp() string {
-	return strconv.Itoa(int(time.Now().Unix()))
+func builtinTimestamp(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(0, "timestamp", inputValues); err != nil {
+		return nil, err
+	}
+	now, err := env.GetEvalPolicy().Now("timestamp")
+	if err != nil {
+		return nil, err
+	}
+	return LiftString(strconv.Itoa(int(now.Unix()))), nil
 }
 
-func builtinAdd(x, y int) int {
-	return x + y
+// The result is an integer if both arguments are integers, and a float
+// otherwise.
+func builtinArithmetic(funcName string, op func(x, y float64) (float64, error), intOp func(x, y int) (int, error)) scriptFuncType {
+	return func(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+		arg1, arg2, err := builtinNumericArgs(funcName, inputValues)
+		if err != nil {
+			return nil, err
+		}
+		if IsIntegerAtom(arg1) && IsIntegerAtom(arg2) {
+			result, err := intOp(ExpectIntegerAtom(arg1), ExpectIntegerAtom(arg2))
+			if err != nil {
+				return nil, err
+			}
+			return LiftInteger(result), nil
+		}
+		result, err := op(expectNumber(arg1), expectNumber(arg2))
+		if err != nil {
+			return nil, err
+		}
+		return LiftFloat(result), nil
+	}
 }
+
+var builtinAdd = builtinArithmetic("add",
+	func(x, y float64) (float64, error) { return x + y, nil },
+	func(x, y int) (int, error) { return x + y, nil })
+
+var builtinSubtract = builtinArithmetic("subtract",
+	func(x, y float64) (float64, error) { return x - y, nil },
+	func(x, y int) (int, error) { return x - y, nil })
+
+var builtinMultiply = builtinArithmetic("multiply",
+	func(x, y float64) (float64, error) { return x * y, nil },
+	func(x, y int) (int, error) { return x * y, nil })
+
+var builtinDivide = builtinArithmetic("divide",
+	func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("Division by zero")
+		}
+		return x / y, nil
+	},
+	func(x, y int) (int, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("Division by zero")
+		}
+		return x / y, nil
+	})
+
+var builtinModulo = builtinArithmetic("modulo",
+	func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("Division by zero")
+		}
+		return math.Mod(x, y), nil
+	},
+	func(x, y int) (int, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("Division by zero")
+		}
+		return x % y, nil
+	})
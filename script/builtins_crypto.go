@@ -0,0 +1,263 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+func builtinHash(funcName string, newHash func() hash.Hash) scriptFuncType {
+	return func(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+		args, err := builtinExpectStringArgs(funcName, 1, inputValues)
+		if err != nil {
+			return nil, err
+		}
+		h := newHash()
+		h.Write([]byte(args[0]))
+		return LiftString(hex.EncodeToString(h.Sum(nil))), nil
+	}
+}
+
+var builtinSha256 = builtinHash("sha256", sha256.New)
+var builtinSha1 = builtinHash("sha1", sha1.New)
+var builtinMd5 = builtinHash("md5", md5.New)
+
+func builtinHmacSha256(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("hmac_sha256", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(args[1]))
+	mac.Write([]byte(args[0]))
+	return LiftString(hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+/*
+   UUIDs (RFC 4122)
+*/
+
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+func formatUUID(u []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func parseUUID(str string) ([]byte, error) {
+	if ns, ok := uuidNamespaces[strings.ToLower(str)]; ok {
+		str = ns
+	}
+	if len(str) != 36 || str[8] != '-' || str[13] != '-' || str[18] != '-' || str[23] != '-' {
+		return nil, fmt.Errorf("Expecting UUID, but got '%s'", str)
+	}
+	u, err := hex.DecodeString(strings.Replace(str, "-", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("Expecting UUID, but got '%s'", str)
+	}
+	return u, nil
+}
+
+func setUUIDVersion(u []byte, version byte) string {
+	u[6] = (u[6] & 0x0f) | (version << 4)
+	u[8] = (u[8] & 0x3f) | 0x80
+	return formatUUID(u)
+}
+
+func builtinUUIDv4(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(0, "uuid_v4", inputValues); err != nil {
+		return nil, err
+	}
+	source, err := env.GetEvalPolicy().RandomSource("uuid_v4")
+	if err != nil {
+		return nil, err
+	}
+	u := make([]byte, 16)
+	if _, err := io.ReadFull(source, u); err != nil {
+		return nil, fmt.Errorf("Couldn't generate UUID: %s", err.Error())
+	}
+	return LiftString(setUUIDVersion(u, 4)), nil
+}
+
+func builtinUUIDv5(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("uuid_v5", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := parseUUID(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s in uuid_v5 call", err.Error())
+	}
+	h := sha1.New()
+	h.Write(namespace)
+	h.Write([]byte(args[1]))
+	return LiftString(setUUIDVersion(h.Sum(nil)[:16], 5)), nil
+}
+
+/*
+   Random strings and passwords
+*/
+
+const alphanumericChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+const passwordSymbols = "!#%*+-.:=?@^_~"
+
+// Generates an endless stream of bytes by hashing the key with a counter, so
+// that the same key always produces the same stream.
+type seededByteStream struct {
+	key     []byte
+	counter uint64
+	buf     []byte
+}
+
+func (s *seededByteStream) Read(p []byte) (int, error) {
+	for i := range p {
+		if len(s.buf) == 0 {
+			mac := hmac.New(sha256.New, s.key)
+			binary.Write(mac, binary.BigEndian, s.counter)
+			s.buf = mac.Sum(nil)
+			s.counter++
+		}
+		p[i] = s.buf[0]
+		s.buf = s.buf[1:]
+	}
+	return len(p), nil
+}
+
+// Seeded values are tied to the current deployment, so that the same seed
+// gives the same value every time a deployment is (re)deployed, but different
+// values in other projects, environments and deployments. They are derived
+// from the environment's secret (see ScriptEnvironment.SetSecret), because
+// the seed and the deployment's name are not secret.
+func randomSource(env *ScriptEnvironment, funcName string, inputValues []Script) (io.Reader, error) {
+	if len(inputValues) < 2 {
+		return env.GetEvalPolicy().RandomSource(funcName)
+	}
+	seed := inputValues[1]
+	if !IsStringAtom(seed) {
+		return nil, fmt.Errorf("Expecting string argument in %s call, but got '%s'", funcName, seed.Type().Name())
+	}
+	secret := env.getSecret()
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("Can't derive seeded value in %s call, because the environment doesn't have a secret", funcName)
+	}
+	parts := []string{funcName}
+	this := map[string]Script{}
+	if thisVal, ok := env.rootGlobals()["this"]; ok && IsDictAtom(thisVal) {
+		this = ExpectDictAtom(thisVal)
+	}
+	for _, field := range []string{"project", "environment", "deployment"} {
+		val, ok := this[field]
+		if ok && IsStringAtom(val) {
+			parts = append(parts, ExpectStringAtom(val))
+		} else {
+			parts = append(parts, "")
+		}
+	}
+	parts = append(parts, ExpectStringAtom(seed))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join(parts, "\x00")))
+	return &seededByteStream{key: mac.Sum(nil)}, nil
+}
+
+func randomChars(source io.Reader, length int, chars string) (string, error) {
+	// Bytes above the largest multiple of len(chars) are skipped, so that
+	// every character is equally likely.
+	limit := 256 - (256 % len(chars))
+	result := make([]byte, 0, length)
+	b := make([]byte, 1)
+	for len(result) < length {
+		if _, err := io.ReadFull(source, b); err != nil {
+			return "", err
+		}
+		if int(b[0]) < limit {
+			result = append(result, chars[int(b[0])%len(chars)])
+		}
+	}
+	return string(result), nil
+}
+
+func builtinRandomArgs(env *ScriptEnvironment, funcName string, inputValues []Script) (int, io.Reader, error) {
+	if len(inputValues) < 1 || len(inputValues) > 2 {
+		return 0, nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 2) in call to '%s', got %d",
+			1, funcName, len(inputValues))
+	}
+	lengthArg := inputValues[0]
+	if !IsIntegerAtom(lengthArg) {
+		return 0, nil, fmt.Errorf("Expecting integer argument in %s call, but got '%s'", funcName, lengthArg.Type().Name())
+	}
+	length := ExpectIntegerAtom(lengthArg)
+	if length < 0 {
+		return 0, nil, fmt.Errorf("Expecting positive length in %s call, but got '%d'", funcName, length)
+	}
+	source, err := randomSource(env, funcName, inputValues)
+	if err != nil {
+		return 0, nil, err
+	}
+	return length, source, nil
+}
+
+func builtinRandomString(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	length, source, err := builtinRandomArgs(env, "random_string", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	str, err := randomChars(source, length, alphanumericChars)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't generate random string: %s", err.Error())
+	}
+	return LiftString(str), nil
+}
+
+func isValidPassword(password string) bool {
+	for _, class := range []string{"abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "0123456789", passwordSymbols} {
+		if !strings.ContainsAny(password, class) {
+			return false
+		}
+	}
+	return true
+}
+
+func builtinPassword(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	length, source, err := builtinRandomArgs(env, "password", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	if length < 4 {
+		return nil, fmt.Errorf("Expecting a length of at least 4 in password call, but got '%d'", length)
+	}
+	for {
+		password, err := randomChars(source, length, alphanumericChars+passwordSymbols)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't generate password: %s", err.Error())
+		}
+		if isValidPassword(password) {
+			return LiftString(password), nil
+		}
+	}
+}
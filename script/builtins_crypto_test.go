@@ -0,0 +1,187 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"bytes"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+func deploymentEnv(project, environment, deployment string) *ScriptEnvironment {
+	env := deploymentEnvWithoutSecret(project, environment, deployment)
+	env.SetSecret("deployment-secret")
+	return env
+}
+
+func deploymentEnvWithoutSecret(project, environment, deployment string) *ScriptEnvironment {
+	return NewScriptEnvironmentWithGlobals(map[string]Script{
+		"this": LiftDict(map[string]Script{
+			"project":     LiftString(project),
+			"environment": LiftString(environment),
+			"deployment":  LiftString(deployment),
+		}),
+	})
+}
+
+func evalCrypto(c *C, expr string, env *ScriptEnvironment) (interface{}, error) {
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_hash_functions(c *C) {
+	cases := map[string]interface{}{
+		`$__sha256("hello")`:                "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		`$__sha1("hello")`:                  "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		`$__md5("hello")`:                   "5d41402abc4b2a76b9719d911017c592",
+		`$__hmac_sha256("hello", "secret")`: "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		`$__uuid_v5("dns", "python.org")`:   "886313e1-3b8a-5372-9b90-0c9aee199e5d",
+		`$__uuid_v5("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "python.org")`: "886313e1-3b8a-5372-9b90-0c9aee199e5d",
+		`$__uuid_v5("URL", "https://example.com")`:                         "4fd35a71-71ef-5a55-a9d9-aa75c889a6d0",
+		`$__random_string(0).length()`:                                     0,
+		`$__random_string(32).length()`:                                    32,
+		`$__password(20, "db").length()`:                                   20,
+		`$__equals($__random_string(16, "x"), $__random_string(16, "x"))`:  true,
+		`$__equals($__random_string(16, "x"), $__random_string(16, "y"))`:  false,
+		`$__equals($__random_string(16, "x"), $__password(16, "x"))`:       false,
+		`$__equals($__random_string(32), $__random_string(32))`:            false,
+		`$__equals($__uuid_v4(), $__uuid_v4())`:                            false,
+	}
+	env := deploymentEnv("project", "dev", "app")
+	for expr, expected := range cases {
+		val, err := evalCrypto(c, expr, env)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_uuid_v4(c *C) {
+	val, err := evalCrypto(c, `$__uuid_v4()`, NewScriptEnvironmentWithGlobals(nil))
+	c.Assert(err, IsNil)
+	c.Assert(val, Matches, "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}")
+}
+
+func (s *exprSuite) Test_Builtin_random_functions_honour_EvalPolicy(c *C) {
+	env := deploymentEnv("project", "dev", "app")
+	env.SetEvalPolicy(NewSandboxEvalPolicy())
+	for _, fun := range []string{"uuid_v4", "random_string", "password"} {
+		args := ""
+		if fun != "uuid_v4" {
+			args = "16"
+		}
+		_, err := evalCrypto(c, "$__"+fun+"("+args+")", env)
+		c.Assert(err, ErrorMatches, "Generating random values in '"+fun+"' is not allowed by the evaluation policy")
+	}
+	val, err := evalCrypto(c, `$__password(16, "seed")`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, HasLen, 16)
+
+	policy := NewEvalPolicy()
+	policy.Random = bytes.NewReader(make([]byte, 16))
+	env.SetEvalPolicy(policy)
+	val, err = evalCrypto(c, `$__uuid_v4()`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "00000000-0000-4000-8000-000000000000")
+	policy.Random = bytes.NewReader([]byte("abc"))
+	val, err = evalCrypto(c, `$__random_string(3)`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "JKL")
+}
+
+func (s *exprSuite) Test_Builtin_random_string_is_deterministic_per_deployment(c *C) {
+	expr := `$__random_string(24, "db_password")`
+	first, err := evalCrypto(c, expr, deploymentEnv("project", "dev", "app"))
+	c.Assert(err, IsNil)
+	c.Assert(first, Matches, "[a-zA-Z0-9]{24}")
+	again, err := evalCrypto(c, expr, deploymentEnv("project", "dev", "app"))
+	c.Assert(err, IsNil)
+	c.Assert(again, Equals, first)
+	for _, env := range []*ScriptEnvironment{
+		deploymentEnv("other-project", "dev", "app"),
+		deploymentEnv("project", "prod", "app"),
+		deploymentEnv("project", "dev", "app2"),
+	} {
+		other, err := evalCrypto(c, expr, env)
+		c.Assert(err, IsNil)
+		c.Assert(other, Not(Equals), first)
+	}
+}
+
+func (s *exprSuite) Test_Builtin_random_string_can_not_be_reproduced_without_secret(c *C) {
+	expr := `$__random_string(24, "db_password")`
+	first, err := evalCrypto(c, expr, deploymentEnv("project", "dev", "app"))
+	c.Assert(err, IsNil)
+
+	otherSecret := deploymentEnvWithoutSecret("project", "dev", "app")
+	otherSecret.SetSecret("guessed-secret")
+	other, err := evalCrypto(c, expr, otherSecret)
+	c.Assert(err, IsNil)
+	c.Assert(other, Not(Equals), first)
+
+	_, err = evalCrypto(c, expr, deploymentEnvWithoutSecret("project", "dev", "app"))
+	c.Assert(err, ErrorMatches, "Can't derive seeded value in random_string call, because the environment doesn't have a secret")
+	_, err = evalCrypto(c, `$__env_lookup("__secret")`, deploymentEnv("project", "dev", "app"))
+	c.Assert(err, ErrorMatches, "Field '__secret' was not found in environment.")
+}
+
+func (s *exprSuite) Test_Builtin_random_string_ignores_shadowed_this(c *C) {
+	first, err := evalCrypto(c, `$__random_string(24, "db_password")`, deploymentEnv("project", "dev", "app"))
+	c.Assert(err, IsNil)
+	fakeThis := `{"project": "project", "environment": "dev", "deployment": "app"}`
+	for _, expr := range []string{
+		`$let(this = ` + fakeThis + `) { $__random_string(24, "db_password") }`,
+		`$func(this) { $__random_string(24, "db_password") }(` + fakeThis + `)`,
+	} {
+		val, err := evalCrypto(c, expr, deploymentEnv("other-project", "prod", "other"))
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, Not(Equals), first, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_password(c *C) {
+	env := deploymentEnv("project", "dev", "app")
+	for _, expr := range []string{`$__password(4)`, `$__password(4, "seed")`, `$__password(64)`} {
+		val, err := evalCrypto(c, expr, env)
+		c.Assert(err, IsNil)
+		password := val.(string)
+		for _, class := range []string{"[a-z]", "[A-Z]", "[0-9]", "[^a-zA-Z0-9]"} {
+			c.Assert(regexp.MustCompile(class).MatchString(password), Equals, true,
+				Commentf("Password '%s' should match '%s'", password, class))
+		}
+	}
+}
+
+func (s *exprSuite) Test_Builtin_crypto_functions_fail(c *C) {
+	cases := map[string]string{
+		`$__sha256(1)`:            "Expecting string argument in sha256 call, but got 'integer'",
+		`$__hmac_sha256("a")`:     "Expecting 2 argument\\(s\\) in call to 'hmac_sha256', got 1",
+		`$__uuid_v4("a")`:         "Expecting 0 argument\\(s\\) in call to 'uuid_v4', got 1",
+		`$__uuid_v5("nope", "a")`: "Expecting UUID, but got 'nope' in uuid_v5 call",
+		`$__random_string("a")`:   "Expecting integer argument in random_string call, but got 'string'",
+		`$__random_string(-1)`:    "Expecting positive length in random_string call, but got '-1'",
+		`$__random_string(1, 2)`:  "Expecting string argument in random_string call, but got 'integer'",
+		`$__random_string()`:      "Expecting at least 1 argument\\(s\\) \\(but not more than 2\\) in call to 'random_string', got 0",
+		`$__password(3)`:          "Expecting a length of at least 4 in password call, but got '3'",
+	}
+	for expr, expected := range cases {
+		_, err := evalCrypto(c, expr, NewScriptEnvironmentWithGlobals(nil))
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
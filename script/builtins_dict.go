@@ -0,0 +1,164 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"sort"
+)
+
+func builtinExpectDictArg(funcName string, arg Script) (map[string]Script, error) {
+	if !IsDictAtom(arg) {
+		return nil, fmt.Errorf("Expecting dict argument in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	return ExpectDictAtom(arg), nil
+}
+
+// Keys are returned in sorted order, so that the results of keys, values and
+// items are deterministic and line up with each other.
+func sortedDictKeys(d map[string]Script) []string {
+	keys := []string{}
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func builtinKeys(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "keys", inputValues); err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDictArg("keys", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	return Lift(sortedDictKeys(d))
+}
+
+func builtinValues(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "values", inputValues); err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDictArg("values", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, key := range sortedDictKeys(d) {
+		result = append(result, d[key])
+	}
+	return LiftList(result), nil
+}
+
+func builtinItems(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "items", inputValues); err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDictArg("items", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, key := range sortedDictKeys(d) {
+		result = append(result, LiftDict(map[string]Script{
+			"key":   LiftString(key),
+			"value": d[key],
+		}))
+	}
+	return LiftList(result), nil
+}
+
+func builtinHasKey(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "has_key", inputValues); err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDictArg("has_key", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	keyArg := inputValues[1]
+	if !IsStringAtom(keyArg) {
+		return nil, fmt.Errorf("Expecting string argument in has_key call, but got '%s'", keyArg.Type().Name())
+	}
+	_, ok := d[ExpectStringAtom(keyArg)]
+	return LiftBool(ok), nil
+}
+
+func builtinGet(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 2 || len(inputValues) > 3 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+			2, "get", len(inputValues))
+	}
+	d, err := builtinExpectDictArg("get", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	keyArg := inputValues[1]
+	if !IsStringAtom(keyArg) {
+		return nil, fmt.Errorf("Expecting string argument in get call, but got '%s'", keyArg.Type().Name())
+	}
+	key := ExpectStringAtom(keyArg)
+	if val, ok := d[key]; ok {
+		return val, nil
+	}
+	if len(inputValues) == 3 {
+		return inputValues[2], nil
+	}
+	return nil, fmt.Errorf("Field '%s' was not found in get call and no default was given", key)
+}
+
+func builtinMerge(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 1 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) in call to '%s', got %d", 1, "merge", len(inputValues))
+	}
+	result := map[string]Script{}
+	for _, arg := range inputValues {
+		d, err := builtinExpectDictArg("merge", arg)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range d {
+			result[key] = val
+		}
+	}
+	return LiftDict(result), nil
+}
+
+func builtinWithout(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 1 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) in call to '%s', got %d", 1, "without", len(inputValues))
+	}
+	d, err := builtinExpectDictArg("without", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	remove := map[string]bool{}
+	for _, arg := range inputValues[1:] {
+		if !IsStringAtom(arg) {
+			return nil, fmt.Errorf("Expecting string argument in without call, but got '%s'", arg.Type().Name())
+		}
+		remove[ExpectStringAtom(arg)] = true
+	}
+	result := map[string]Script{}
+	for key, val := range d {
+		if !remove[key] {
+			result[key] = val
+		}
+	}
+	return LiftDict(result), nil
+}
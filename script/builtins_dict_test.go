@@ -0,0 +1,93 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalDict(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"labels": LiftDict(map[string]Script{
+			"app":  LiftString("web"),
+			"tier": LiftString("frontend"),
+		}),
+		"provider_labels": LiftDict(map[string]Script{
+			"tier":   LiftString("backend"),
+			"region": LiftString("eu"),
+		}),
+		"empty": LiftDict(map[string]Script{}),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_dict_functions(c *C) {
+	cases := map[string]interface{}{
+		`$labels.keys()`:   []interface{}{"app", "tier"},
+		`$empty.keys()`:    []interface{}{},
+		`$labels.values()`: []interface{}{"web", "frontend"},
+		`$labels.items().map($func(i) { $i.key.concat("=", $i.value) })`: []interface{}{"app=web", "tier=frontend"},
+		`$labels.has_key("app")`:                        true,
+		`$labels.has_key("region")`:                     false,
+		`$labels.get("app", "default")`:                 "web",
+		`$labels.get("region", "default")`:              "default",
+		`$labels.get("app")`:                            "web",
+		`$labels.merge($provider_labels).keys()`:        []interface{}{"app", "region", "tier"},
+		`$labels.merge($provider_labels).tier`:          "backend",
+		`$provider_labels.merge($labels).tier`:          "frontend",
+		`$labels.merge().keys()`:                        []interface{}{"app", "tier"},
+		`$labels.merge({"a": 1}, {"a": 2}).a`:           2,
+		`$labels.without("app").keys()`:                 []interface{}{"tier"},
+		`$labels.without("app", "tier", "nope").keys()`: []interface{}{},
+		`$labels.without().keys()`:                      []interface{}{"app", "tier"},
+		`$__has_key({"x": 1}, "x")`:                     true,
+	}
+	for expr, expected := range cases {
+		val, err := evalDict(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_dict_functions_do_not_modify_their_arguments(c *C) {
+	_, err := evalDict(c, `$labels.merge($provider_labels).without("app")`)
+	c.Assert(err, IsNil)
+	val, err := evalDict(c, `$labels.keys()`)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, []interface{}{"app", "tier"})
+}
+
+func (s *exprSuite) Test_Builtin_dict_functions_fail(c *C) {
+	cases := map[string]string{
+		`$__keys([])`:           "Expecting dict argument in keys call, but got 'list'",
+		`$__values("a")`:        "Expecting dict argument in values call, but got 'string'",
+		`$__items(1)`:           "Expecting dict argument in items call, but got 'integer'",
+		`$labels.has_key(1)`:    "Expecting string argument in has_key call, but got 'integer'",
+		`$labels.get("region")`: "Field 'region' was not found in get call and no default was given",
+		`$labels.get()`:         "Expecting at least 2 argument\\(s\\) \\(but not more than 3\\) in call to 'get', got 1",
+		`$labels.merge([])`:     "Expecting dict argument in merge call, but got 'list'",
+		`$__merge()`:            "Expecting at least 1 argument\\(s\\) in call to 'merge', got 0",
+		`$labels.without(1)`:    "Expecting string argument in without call, but got 'integer'",
+	}
+	for expr, expected := range cases {
+		_, err := evalDict(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
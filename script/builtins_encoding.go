@@ -0,0 +1,234 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Converts a script value into plain Go values that can be marshalled.
+// Functions can't be encoded, so they are rejected instead of being silently
+// dropped by the encoder.
+func encodableValue(funcName string, s Script) (interface{}, error) {
+	if IsDictAtom(s) {
+		result := map[string]interface{}{}
+		for key, val := range ExpectDictAtom(s) {
+			v, err := encodableValue(funcName, val)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+	}
+	if IsListAtom(s) {
+		result := []interface{}{}
+		for _, val := range ExpectListAtom(s) {
+			v, err := encodableValue(funcName, val)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+	if IsFloatAtom(s) {
+		return encodableFloat(ExpectFloatAtom(s)), nil
+	}
+	if IsStringAtom(s) || IsIntegerAtom(s) || IsBoolAtom(s) || IsNullAtom(s) {
+		return s.Value()
+	}
+	return nil, fmt.Errorf("Can't encode value of type '%s' in %s call", s.Type().Name(), funcName)
+}
+
+// Floats are encoded with a decimal point, so that they are decoded as floats
+// again (e.g. 2.0 instead of 2).
+type encodableFloat float64
+
+func (f encodableFloat) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(f), 0) || math.IsNaN(float64(f)) {
+		return nil, fmt.Errorf("Unsupported float value %v", float64(f))
+	}
+	return []byte(FormatFloat(float64(f))), nil
+}
+
+func builtinToJson(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "to_json", inputValues); err != nil {
+		return nil, err
+	}
+	val, err := encodableValue("to_json", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	str, err := json.Marshal(val)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't encode value in to_json call: %s", err.Error())
+	}
+	return LiftString(string(str)), nil
+}
+
+func builtinFromJson(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("from_json", 1, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	decoder := json.NewDecoder(strings.NewReader(args[0]))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("Couldn't decode JSON in from_json call: %s", err.Error())
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Couldn't decode JSON in from_json call: unexpected data after top-level value")
+	}
+	val, err := Lift(liftDecodedValues(result))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode JSON in from_json call: %s", err.Error())
+	}
+	return val, nil
+}
+
+func builtinToYaml(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "to_yaml", inputValues); err != nil {
+		return nil, err
+	}
+	val, err := encodableValue("to_yaml", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	str, err := marshalYaml(val)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't encode value in to_yaml call: %s", err.Error())
+	}
+	return LiftString(str), nil
+}
+
+// The YAML encoder formats whole floats as integers (e.g. 2 instead of 2.0)
+// and can't be told otherwise, so floats are encoded as placeholders, which
+// are then replaced by the formatted floats. The prefix is made longer until
+// it doesn't occur in the rest of the document.
+func marshalYaml(val interface{}) (string, error) {
+	for prefix := "float"; ; prefix += "_" {
+		floats := []string{}
+		placeholder := func(f encodableFloat) (interface{}, error) {
+			if math.IsInf(float64(f), 0) || math.IsNaN(float64(f)) {
+				return nil, fmt.Errorf("Unsupported float value %v", float64(f))
+			}
+			floats = append(floats, FormatFloat(float64(f)))
+			return fmt.Sprintf("%s%dx", prefix, len(floats)-1), nil
+		}
+		withPlaceholders, err := replaceFloats(val, placeholder)
+		if err != nil {
+			return "", err
+		}
+		str, err := yaml.Marshal(withPlaceholders)
+		if err != nil {
+			return "", err
+		}
+		result := string(str)
+		if strings.Count(result, prefix) != len(floats) {
+			continue
+		}
+		for ix, f := range floats {
+			result = strings.Replace(result, fmt.Sprintf("%s%dx", prefix, ix), f, 1)
+		}
+		return result, nil
+	}
+}
+
+func replaceFloats(val interface{}, replace func(encodableFloat) (interface{}, error)) (interface{}, error) {
+	switch val.(type) {
+	case encodableFloat:
+		return replace(val.(encodableFloat))
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, v := range val.(map[string]interface{}) {
+			replaced, err := replaceFloats(v, replace)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = replaced
+		}
+		return result, nil
+	case []interface{}:
+		result := []interface{}{}
+		for _, v := range val.([]interface{}) {
+			replaced, err := replaceFloats(v, replace)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, replaced)
+		}
+		return result, nil
+	}
+	return val, nil
+}
+
+// Lifts the values in decoded JSON and YAML documents that Lift would get
+// wrong: Lift turns nil into an empty string, but null values in documents
+// should stay null. The YAML decoder only uses float64 for numbers that are
+// written as floats, so unlike JSON numbers they can be lifted to floats
+// directly.
+func liftDecodedValues(val interface{}) interface{} {
+	switch val.(type) {
+	case nil:
+		return LiftNull()
+	case float64:
+		return LiftFloat(val.(float64))
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, v := range val.(map[string]interface{}) {
+			result[key] = liftDecodedValues(v)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := map[interface{}]interface{}{}
+		for key, v := range val.(map[interface{}]interface{}) {
+			result[key] = liftDecodedValues(v)
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, v := range val.([]interface{}) {
+			result = append(result, liftDecodedValues(v))
+		}
+		return result
+	}
+	return val
+}
+
+func builtinFromYaml(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("from_yaml", 1, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := yaml.Unmarshal([]byte(args[0]), &result); err != nil {
+		return nil, fmt.Errorf("Couldn't decode YAML in from_yaml call: %s", err.Error())
+	}
+	val, err := Lift(liftDecodedValues(result))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode YAML in from_yaml call: %s", err.Error())
+	}
+	return val, nil
+}
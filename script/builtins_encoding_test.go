@@ -0,0 +1,93 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalEncoding(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"config": LiftDict(map[string]Script{
+			"name":     LiftString("app"),
+			"replicas": LiftInteger(3),
+			"debug":    LiftBool(false),
+			"ports":    LiftList([]Script{LiftInteger(80), LiftInteger(443)}),
+		}),
+		"fn":     LiftFunction(builtinId),
+		"output": LiftString(`{"endpoint": {"host": "db.local", "port": 5432}, "tags": ["a", "b"]}`),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_encoding_functions(c *C) {
+	cases := map[string]interface{}{
+		`$config.to_json()`:                       `{"debug":false,"name":"app","ports":[80,443],"replicas":3}`,
+		`$config.ports.to_json()`:                 `[80,443]`,
+		`$__to_json("a\"b")`:                      `"a\"b"`,
+		`$__to_json({})`:                          `{}`,
+		`$config.to_yaml()`:                       "debug: false\nname: app\nports:\n- 80\n- 443\nreplicas: 3\n",
+		`$config.ports.to_yaml()`:                 "- 80\n- 443\n",
+		`$output.from_json().endpoint.host`:       "db.local",
+		`$output.from_json().endpoint.port`:       5432,
+		`$output.from_json().tags`:                []interface{}{"a", "b"},
+		`$output.from_json().tags.join(",")`:      "a,b",
+		`$__from_json("[true, \"x\", 1]")`:        []interface{}{true, "x", 1},
+		`$__from_json("\"str\"")`:                 "str",
+		`$__from_yaml("a:\n  b: [1, 2]\n").a.b`:   []interface{}{1, 2},
+		`$__from_yaml("- a\n- b\n")`:              []interface{}{"a", "b"},
+		`$config.to_json().from_json().to_json()`: `{"debug":false,"name":"app","ports":[80,443],"replicas":3}`,
+		`$__from_json("[1, 1.0, 0.5, -2.5e3]")`:   []interface{}{1, 1.0, 0.5, -2500.0},
+		`$__to_json([1, 1.0, 0.5, -2.5e3])`:       "[1,1.0,0.5,-2500.0]",
+		`$__from_json("[1, 1.0, 0.5]").to_json()`: "[1,1.0,0.5]",
+		`$__from_yaml("a: 0.5").a`:                0.5,
+		`$__from_yaml("a: 2.0").a`:                2.0,
+		`$__from_yaml("a: 2").a`:                  2,
+		`$__from_yaml("a: ~").a == $null`:         true,
+		`$__from_yaml("[1, null]")[1] == $null`:   true,
+		`$__from_json("[1, null]")[1] == $null`:   true,
+		`$__to_yaml({"a": 2.0, "b": [0.5, 2]})`:   "a: 2.0\nb:\n- 0.5\n- 2\n",
+		`$__to_yaml({"float0x": 1.0})`:            "float0x: 1.0\n",
+		`$__to_yaml(2.0).from_yaml()`:             2.0,
+		`$config.to_yaml().from_yaml().to_json()`: `{"debug":false,"name":"app","ports":[80,443],"replicas":3}`,
+	}
+	for expr, expected := range cases {
+		val, err := evalEncoding(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_encoding_functions_fail(c *C) {
+	cases := map[string]string{
+		`$__to_json([$func(x) { $x }])`: "Can't encode value of type 'lambda' in to_json call",
+		`$__to_yaml({"f": $fn})`:        "Can't encode value of type 'func' in to_yaml call",
+		`$__to_json()`:                  "Expecting 1 argument\\(s\\) in call to 'to_json', got 0",
+		`$__from_json("{")`:             "Couldn't decode JSON in from_json call: unexpected EOF",
+		`$__from_json("{} []")`:         "Couldn't decode JSON in from_json call: unexpected data after top-level value",
+		`$__from_json(1)`:               "Expecting string argument in from_json call, but got 'integer'",
+		`$__from_yaml("a: [")`:          "Couldn't decode YAML in from_yaml call: .*",
+		`$__from_yaml("1: a")`:          "Couldn't decode YAML in from_yaml call: Expecting string key for dictionary type, but got int",
+	}
+	for expr, expected := range cases {
+		_, err := evalEncoding(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
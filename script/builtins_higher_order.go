@@ -0,0 +1,212 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Calls a func or lambda value with already evaluated arguments.
+func builtinCallFunction(env *ScriptEnvironment, fun Script, args []Script) (Script, error) {
+	return NewApply(fun, args).Eval(env)
+}
+
+func builtinCallPredicate(env *ScriptEnvironment, funcName string, fun Script, item Script) (bool, error) {
+	result, err := builtinCallFunction(env, fun, []Script{item})
+	if err != nil {
+		return false, err
+	}
+	if !IsBoolAtom(result) {
+		return false, fmt.Errorf("Expecting function in %s call to return bool, but got '%s'", funcName, result.Type().Name())
+	}
+	return ExpectBoolAtom(result), nil
+}
+
+func builtinExpectListAndFunction(funcName string, inputValues []Script) ([]Script, Script, error) {
+	lstArg := inputValues[0]
+	if !IsListAtom(lstArg) {
+		return nil, nil, fmt.Errorf("Expecting list argument in %s call, but got '%s'", funcName, lstArg.Type().Name())
+	}
+	fun := inputValues[1]
+	if !fun.Type().IsFunc() && !fun.Type().IsLambda() {
+		return nil, nil, fmt.Errorf("Expecting function argument in %s call, but got '%s'", funcName, fun.Type().Name())
+	}
+	return ExpectListAtom(lstArg), fun, nil
+}
+
+func builtinMap(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "map", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("map", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, item := range lst {
+		val, err := builtinCallFunction(env, fun, []Script{item})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, val)
+	}
+	return LiftList(result), nil
+}
+
+func builtinFilter(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "filter", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("filter", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, item := range lst {
+		keep, err := builtinCallPredicate(env, "filter", fun, item)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result = append(result, item)
+		}
+	}
+	return LiftList(result), nil
+}
+
+func builtinReduce(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(3, "reduce", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("reduce", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := inputValues[2]
+	for _, item := range lst {
+		result, err = builtinCallFunction(env, fun, []Script{result, item})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func builtinAny(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "any", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("any", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range lst {
+		ok, err := builtinCallPredicate(env, "any", fun, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return LiftBool(true), nil
+		}
+	}
+	return LiftBool(false), nil
+}
+
+func builtinAll(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "all", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("all", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range lst {
+		ok, err := builtinCallPredicate(env, "all", fun, item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return LiftBool(false), nil
+		}
+	}
+	return LiftBool(true), nil
+}
+
+func builtinFind(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 2 || len(inputValues) > 3 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+			2, "find", len(inputValues))
+	}
+	lst, fun, err := builtinExpectListAndFunction("find", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range lst {
+		ok, err := builtinCallPredicate(env, "find", fun, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return item, nil
+		}
+	}
+	if len(inputValues) == 3 {
+		return inputValues[2], nil
+	}
+	return nil, fmt.Errorf("No item in list matched in find call (pass a default value as the third argument to avoid this error)")
+}
+
+func builtinSortBy(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "sort_by", inputValues); err != nil {
+		return nil, err
+	}
+	lst, fun, err := builtinExpectListAndFunction("sort_by", inputValues)
+	if err != nil {
+		return nil, err
+	}
+	keys := []Script{}
+	for _, item := range lst {
+		key, err := builtinCallFunction(env, fun, []Script{item})
+		if err != nil {
+			return nil, err
+		}
+		if !IsIntegerAtom(key) && !IsStringAtom(key) {
+			return nil, fmt.Errorf("Expecting function in sort_by call to return integer or string, but got '%s'", key.Type().Name())
+		}
+		if len(keys) > 0 && key.Type().Name() != keys[0].Type().Name() {
+			return nil, fmt.Errorf("Can't compare sort keys of type '%s' and '%s' in sort_by call", keys[0].Type().Name(), key.Type().Name())
+		}
+		keys = append(keys, key)
+	}
+	indices := make([]int, len(lst))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		k1, k2 := keys[indices[i]], keys[indices[j]]
+		if IsIntegerAtom(k1) {
+			return ExpectIntegerAtom(k1) < ExpectIntegerAtom(k2)
+		}
+		return ExpectStringAtom(k1) < ExpectStringAtom(k2)
+	})
+	result := []Script{}
+	for _, i := range indices {
+		result = append(result, lst[i])
+	}
+	return LiftList(result), nil
+}
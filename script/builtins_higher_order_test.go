@@ -0,0 +1,89 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func evalHigherOrder(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"hosts": ShouldLift([]interface{}{"web-2", "db-1", "web-1"}),
+		"ints":  ShouldLift([]interface{}{3, 1, 2}),
+		"empty": LiftList([]Script{}),
+		"upper": ShouldLift(strings.ToUpper),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_higher_order_functions(c *C) {
+	cases := map[string]interface{}{
+		`$hosts.map($func(h) { $h.concat(".local") })`:                   []interface{}{"web-2.local", "db-1.local", "web-1.local"},
+		`$hosts.map($upper)`:                                             []interface{}{"WEB-2", "DB-1", "WEB-1"},
+		`$empty.map($upper)`:                                             []interface{}{},
+		`$ints.map($func(i) { $i * 2 })`:                                 []interface{}{6, 2, 4},
+		`$hosts.filter($func(h) { $h.split("-")[0] == "web" })`:          []interface{}{"web-2", "web-1"},
+		`$ints.filter($func(i) { $i > 5 })`:                              []interface{}{},
+		`$ints.reduce($func(acc, i) { $acc + $i }, 0)`:                   6,
+		`$hosts.reduce($func(acc, h) { $acc.concat($h) }, "")`:           "web-2db-1web-1",
+		`$empty.reduce($func(acc, i) { $acc + $i }, 42)`:                 42,
+		`$ints.any($func(i) { $i == 2 })`:                                true,
+		`$ints.any($func(i) { $i == 4 })`:                                false,
+		`$empty.any($func(i) { $i == 4 })`:                               false,
+		`$ints.all($func(i) { $i > 0 })`:                                 true,
+		`$ints.all($func(i) { $i > 1 })`:                                 false,
+		`$empty.all($func(i) { $i > 1 })`:                                true,
+		`$ints.sort_by($func(i) { $i })`:                                 []interface{}{1, 2, 3},
+		`$ints.sort_by($func(i) { 0 - $i })`:                             []interface{}{3, 2, 1},
+		`$hosts.sort_by($func(h) { $h })`:                                []interface{}{"db-1", "web-1", "web-2"},
+		`$hosts.sort_by($func(h) { $h.split("-")[0] })`:                  []interface{}{"db-1", "web-2", "web-1"},
+		`$hosts.find($func(h) { $h.split("-")[0] == "web" })`:            "web-2",
+		`$hosts.find($func(h) { $h == "none" }, "default")`:              "default",
+		`$hosts.filter($func(h) { $h != "db-1" }).map($upper).join(",")`: "WEB-2,WEB-1",
+		`$__map(["a", "b"], $func(x) { $x.upper() })`:                    []interface{}{"A", "B"},
+	}
+	for testCase, expected := range cases {
+		result, err := evalHigherOrder(c, testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_higher_order_functions_failing_cases(c *C) {
+	cases := []string{
+		`$hosts.map("not a function")`,
+		`$hosts.map()`,
+		`$__map("not a list", $upper)`,
+		`$hosts.map($func(a, b) { $a })`,
+		`$hosts.filter($upper)`,
+		`$ints.any($func(i) { $i })`,
+		`$ints.all($func(i) { $i })`,
+		`$ints.reduce($func(acc, i) { $acc + $i })`,
+		`$ints.sort_by($func(i) { [$i] })`,
+		`$ints.sort_by($func(i) { $if($i == 1, "a", $i) })`,
+		`$hosts.find($func(h) { $h == "none" })`,
+		`$hosts.find($upper)`,
+	}
+	for _, testCase := range cases {
+		_, err := evalHigherOrder(c, testCase)
+		c.Assert(err, Not(IsNil), Commentf("Should have failed '%s'", testCase))
+	}
+}
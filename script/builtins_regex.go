@@ -0,0 +1,142 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Compiled patterns are cached, because scripts tend to use the same
+// patterns over and over (e.g. in a map or filter call).
+type regexCache struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+const maxCachedRegexes = 256
+
+var compiledRegexes = &regexCache{
+	patterns: map[string]*regexp.Regexp{},
+}
+
+func (r *regexCache) Compile(funcName, pattern string) (*regexp.Regexp, error) {
+	r.Lock()
+	defer r.Unlock()
+	if re, ok := r.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression '%s' in %s call: %s", pattern, funcName, err.Error())
+	}
+	if len(r.patterns) >= maxCachedRegexes {
+		r.patterns = map[string]*regexp.Regexp{}
+	}
+	r.patterns[pattern] = re
+	return re, nil
+}
+
+func builtinExpectStringArgs(funcName string, expected int, inputValues []Script) ([]string, error) {
+	if err := builtinArgCheck(expected, funcName, inputValues); err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, arg := range inputValues {
+		if !IsStringAtom(arg) {
+			return nil, fmt.Errorf("Expecting string argument in %s call, but got '%s'", funcName, arg.Type().Name())
+		}
+		result = append(result, ExpectStringAtom(arg))
+	}
+	return result, nil
+}
+
+// Returns the string value and the compiled pattern, followed by any other
+// string arguments.
+func builtinExpectRegexArgs(funcName string, expected int, inputValues []Script) (string, *regexp.Regexp, []string, error) {
+	args, err := builtinExpectStringArgs(funcName, expected, inputValues)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	re, err := compiledRegexes.Compile(funcName, args[1])
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return args[0], re, args[2:], nil
+}
+
+// Returns the first capture group if the pattern has any, or the whole match
+// otherwise.
+func regexMatchValue(re *regexp.Regexp, match []string) string {
+	if re.NumSubexp() > 0 {
+		return match[1]
+	}
+	return match[0]
+}
+
+func builtinMatches(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	str, re, _, err := builtinExpectRegexArgs("matches", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	return LiftBool(re.MatchString(str)), nil
+}
+
+func builtinRegexFind(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	str, re, _, err := builtinExpectRegexArgs("regex_find", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	match := re.FindStringSubmatch(str)
+	if match == nil {
+		return LiftString(""), nil
+	}
+	return LiftString(regexMatchValue(re, match)), nil
+}
+
+func builtinRegexFindAll(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	str, re, _, err := builtinExpectRegexArgs("regex_find_all", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, match := range re.FindAllStringSubmatch(str, -1) {
+		result = append(result, LiftString(regexMatchValue(re, match)))
+	}
+	return LiftList(result), nil
+}
+
+func builtinRegexReplace(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	str, re, rest, err := builtinExpectRegexArgs("regex_replace", 3, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	return LiftString(re.ReplaceAllString(str, rest[0])), nil
+}
+
+func builtinRegexSplit(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	str, re, _, err := builtinExpectRegexArgs("regex_split", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, part := range re.Split(str, -1) {
+		result = append(result, LiftString(part))
+	}
+	return LiftList(result), nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+func evalRegex(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"image": LiftString("gcr.io/project/app:v1.2.3"),
+		"hosts": LiftString("web-1, web-2,db-1"),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_regex_functions(c *C) {
+	cases := map[string]interface{}{
+		`$image.matches(":v[0-9]+")`:                                          true,
+		`$image.matches("^v[0-9]+")`:                                          false,
+		`$image.matches("")`:                                                  true,
+		`$image.regex_find("[0-9]+\\.[0-9]+\\.[0-9]+")`:                       "1.2.3",
+		`$image.regex_find(":v([0-9.]+)$")`:                                   "1.2.3",
+		`$image.regex_find("^([a-z.]+)/")`:                                    "gcr.io",
+		`$image.regex_find("nope")`:                                           "",
+		`$hosts.regex_find_all("[a-z]+-[0-9]+")`:                              []interface{}{"web-1", "web-2", "db-1"},
+		`$hosts.regex_find_all("([a-z]+)-[0-9]+")`:                            []interface{}{"web", "web", "db"},
+		`$hosts.regex_find_all("nope")`:                                       []interface{}{},
+		`$hosts.regex_replace("-([0-9]+)", "_$1")`:                            "web_1, web_2,db_1",
+		`$hosts.regex_replace("\\s", "")`:                                     "web-1,web-2,db-1",
+		`$hosts.regex_split(",\\s*")`:                                         []interface{}{"web-1", "web-2", "db-1"},
+		`$hosts.regex_split("nope")`:                                          []interface{}{"web-1, web-2,db-1"},
+		`$hosts.regex_split(",\\s*").filter($func(h) { $h.matches("^web") })`: []interface{}{"web-1", "web-2"},
+	}
+	for expr, expected := range cases {
+		val, err := evalRegex(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_regex_functions_fail(c *C) {
+	cases := map[string]string{
+		`$image.matches("(")`:           ".*Invalid regular expression '\\(' in matches call: error parsing regexp: missing closing \\): `\\(`",
+		`$image.regex_find("[a-")`:      ".*Invalid regular expression '\\[a-' in regex_find call: .*",
+		`$image.regex_find_all("*")`:    ".*Invalid regular expression '\\*' in regex_find_all call: .*",
+		`$image.regex_replace("(", "")`: ".*Invalid regular expression '\\(' in regex_replace call: .*",
+		`$image.regex_split("(")`:       ".*Invalid regular expression '\\(' in regex_split call: .*",
+		`$image.matches(1)`:             ".*Expecting string argument in matches call, but got 'integer'",
+		`$image.regex_replace("a")`:     ".*Expecting 3 argument\\(s\\) in call to 'regex_replace', got 2",
+		`$__regex_find_all([], "a")`:    ".*Expecting string argument in regex_find_all call, but got 'list'",
+	}
+	for expr, expected := range cases {
+		_, err := evalRegex(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_regexCache(c *C) {
+	cache := &regexCache{patterns: map[string]*regexp.Regexp{}}
+	re1, err := cache.Compile("matches", "a+")
+	c.Assert(err, IsNil)
+	re2, err := cache.Compile("matches", "a+")
+	c.Assert(err, IsNil)
+	c.Assert(re1 == re2, Equals, true)
+	for i := 0; i < maxCachedRegexes+1; i++ {
+		_, err := cache.Compile("matches", fmt.Sprintf("a{%d}", i))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(len(cache.patterns) <= maxCachedRegexes, Equals, true)
+	_, err = cache.Compile("matches", "(")
+	c.Assert(err, Not(IsNil))
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The maximum length of strings produced by repeat, pad_left and pad_right.
+const maxBuiltinStringLength = 1 << 20
+
+// Upper cases the first letter of every word and lower cases the rest, e.g.
+// "hello WORLD" becomes "Hello World".
+func builtinTitle(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("title", 1, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []rune{}
+	inWord := false
+	for _, r := range args[0] {
+		if inWord {
+			result = append(result, unicode.ToLower(r))
+		} else {
+			result = append(result, unicode.ToTitle(r))
+		}
+		inWord = unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+	}
+	return LiftString(string(result)), nil
+}
+
+// Returns the index of the first occurrence of substr in v, counted in
+// characters, so that it can be used with substring.
+func builtinIndexOf(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	args, err := builtinExpectStringArgs("index_of", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	ix := strings.Index(args[0], args[1])
+	if ix == -1 {
+		return LiftInteger(-1), nil
+	}
+	return LiftInteger(utf8.RuneCountInString(args[0][:ix])), nil
+}
+
+func builtinExpectIntegerArg(funcName string, arg Script) (int, error) {
+	if !IsIntegerAtom(arg) {
+		return 0, fmt.Errorf("Expecting integer argument in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	return ExpectIntegerAtom(arg), nil
+}
+
+// Negative indices count from the end of the string, like in list slices.
+func builtinSubstring(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 2 || len(inputValues) > 3 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+			2, "substring", len(inputValues))
+	}
+	strArg := inputValues[0]
+	if !IsStringAtom(strArg) {
+		return nil, fmt.Errorf("Expecting string argument in substring call, but got '%s'", strArg.Type().Name())
+	}
+	str := []rune(ExpectStringAtom(strArg))
+	indices := []int{0, len(str)}
+	for i, arg := range inputValues[1:] {
+		ix, err := builtinExpectIntegerArg("substring", arg)
+		if err != nil {
+			return nil, err
+		}
+		if ix < 0 {
+			ix = len(str) + ix
+		}
+		if ix < 0 || ix > len(str) {
+			return nil, fmt.Errorf("Index '%d' out of range in substring call (len: %d)", ExpectIntegerAtom(arg), len(str))
+		}
+		indices[i] = ix
+	}
+	if indices[0] > indices[1] {
+		return nil, fmt.Errorf("Start index '%d' is greater than end index '%d' in substring call", indices[0], indices[1])
+	}
+	return LiftString(string(str[indices[0]:indices[1]])), nil
+}
+
+func builtinPad(funcName string, left bool) scriptFuncType {
+	return func(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+		if len(inputValues) < 2 || len(inputValues) > 3 {
+			return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+				2, funcName, len(inputValues))
+		}
+		strArg := inputValues[0]
+		if !IsStringAtom(strArg) {
+			return nil, fmt.Errorf("Expecting string argument in %s call, but got '%s'", funcName, strArg.Type().Name())
+		}
+		str := ExpectStringAtom(strArg)
+		length, err := builtinExpectIntegerArg(funcName, inputValues[1])
+		if err != nil {
+			return nil, err
+		}
+		if length > maxBuiltinStringLength {
+			return nil, fmt.Errorf("Expecting length of at most %d in %s call, but got '%d'", maxBuiltinStringLength, funcName, length)
+		}
+		padding := " "
+		if len(inputValues) == 3 {
+			padArg := inputValues[2]
+			if !IsStringAtom(padArg) || utf8.RuneCountInString(ExpectStringAtom(padArg)) != 1 {
+				return nil, fmt.Errorf("Expecting single character padding in %s call, but got '%s'", funcName, Print(padArg))
+			}
+			padding = ExpectStringAtom(padArg)
+		}
+		n := length - utf8.RuneCountInString(str)
+		if n <= 0 {
+			return LiftString(str), nil
+		}
+		if left {
+			return LiftString(strings.Repeat(padding, n) + str), nil
+		}
+		return LiftString(str + strings.Repeat(padding, n)), nil
+	}
+}
+
+func builtinRepeat(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "repeat", inputValues); err != nil {
+		return nil, err
+	}
+	strArg := inputValues[0]
+	if !IsStringAtom(strArg) {
+		return nil, fmt.Errorf("Expecting string argument in repeat call, but got '%s'", strArg.Type().Name())
+	}
+	count, err := builtinExpectIntegerArg("repeat", inputValues[1])
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("Expecting positive count in repeat call, but got '%d'", count)
+	}
+	str := ExpectStringAtom(strArg)
+	if len(str) > 0 && count > maxBuiltinStringLength/len(str) {
+		return nil, fmt.Errorf("Expecting result of at most %d bytes in repeat call, but got count '%d'", maxBuiltinStringLength, count)
+	}
+	return LiftString(strings.Repeat(str, count)), nil
+}
+
+// Formats the arguments like Go's fmt.Sprintf, but checks that the
+// arguments match the verbs in the format string, instead of rendering
+// errors like "%!d(string=a)" into the result.
+func builtinFormat(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 1 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) in call to '%s', got %d", 1, "format", len(inputValues))
+	}
+	formatArg := inputValues[0]
+	if !IsStringAtom(formatArg) {
+		return nil, fmt.Errorf("Expecting string format in format call, but got '%s'", formatArg.Type().Name())
+	}
+	format := ExpectStringAtom(formatArg)
+	args := inputValues[1:]
+	result := ""
+	argIx := 0
+	str := format
+	for str != "" {
+		ix := strings.Index(str, "%")
+		if ix == -1 {
+			result += str
+			break
+		}
+		result += str[:ix]
+		str = str[ix:]
+		spec := formatSpec(str)
+		if spec == "" {
+			return nil, fmt.Errorf("Invalid format verb at '%s' in format call", str)
+		}
+		str = str[len(spec):]
+		verb := spec[len(spec)-1]
+		if verb == '%' {
+			result += "%"
+			continue
+		}
+		if argIx >= len(args) {
+			return nil, fmt.Errorf("Missing argument for '%s' in format call", spec)
+		}
+		value, err := formatValue(spec, args[argIx])
+		if err != nil {
+			return nil, err
+		}
+		result += fmt.Sprintf(spec, value)
+		argIx += 1
+	}
+	if argIx != len(args) {
+		return nil, fmt.Errorf("Expecting %d argument(s) for format string '%s' in format call, got %d", argIx, format, len(args))
+	}
+	return LiftString(result), nil
+}
+
+const formatFlags = "+-# 0"
+const formatVerbs = "%vsqdxXobeEfgGt"
+
+// Returns the format specifier (e.g. "%-5s" or "%.2f") at the start of str,
+// or an empty string if it's not valid.
+func formatSpec(str string) string {
+	i := 1
+	for i < len(str) && strings.IndexByte(formatFlags, str[i]) != -1 {
+		i++
+	}
+	for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+		i++
+	}
+	if i < len(str) && str[i] == '.' {
+		i++
+		for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+			i++
+		}
+	}
+	if i >= len(str) || strings.IndexByte(formatVerbs, str[i]) == -1 {
+		return ""
+	}
+	return str[:i+1]
+}
+
+// Returns the Go value that is passed to fmt.Sprintf for the argument, or an
+// error if the argument doesn't match the verb.
+func formatValue(spec string, arg Script) (interface{}, error) {
+	verb := spec[len(spec)-1]
+	typeName := arg.Type().Name()
+	switch verb {
+	case 's', 'q':
+		if !IsStringAtom(arg) {
+			return nil, fmt.Errorf("Expecting string argument for '%s' in format call, but got '%s'", spec, typeName)
+		}
+		return ExpectStringAtom(arg), nil
+	case 'd', 'o', 'b':
+		if !IsIntegerAtom(arg) {
+			return nil, fmt.Errorf("Expecting integer argument for '%s' in format call, but got '%s'", spec, typeName)
+		}
+		return ExpectIntegerAtom(arg), nil
+	case 'x', 'X':
+		if IsStringAtom(arg) {
+			return ExpectStringAtom(arg), nil
+		}
+		if !IsIntegerAtom(arg) {
+			return nil, fmt.Errorf("Expecting integer or string argument for '%s' in format call, but got '%s'", spec, typeName)
+		}
+		return ExpectIntegerAtom(arg), nil
+	case 'e', 'E', 'f', 'g', 'G':
+		if !isNumber(arg) {
+			return nil, fmt.Errorf("Expecting integer or float argument for '%s' in format call, but got '%s'", spec, typeName)
+		}
+		return expectNumber(arg), nil
+	case 't':
+		if !IsBoolAtom(arg) {
+			return nil, fmt.Errorf("Expecting bool argument for '%s' in format call, but got '%s'", spec, typeName)
+		}
+		return ExpectBoolAtom(arg), nil
+	}
+	if IsStringAtom(arg) || IsIntegerAtom(arg) || IsBoolAtom(arg) {
+		return arg.Value()
+	}
+	return printExpression(arg, 0), nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalStrings(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"image": LiftString("gcr.io/project/app:v1.2.3"),
+		"host":  LiftString("db.local"),
+		"port":  LiftInteger(5432),
+		"name":  LiftString("héllo wörld"),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_string_functions(c *C) {
+	cases := map[string]interface{}{
+		`$image.has_prefix("gcr.io/")`:                   true,
+		`$image.has_prefix("docker.io/")`:                false,
+		`$image.has_suffix(":v1.2.3")`:                   true,
+		`$image.contains("/project/")`:                   true,
+		`$image.contains("nope")`:                        false,
+		`$image.index_of(":")`:                           18,
+		`$image.index_of("nope")`:                        -1,
+		`$name.index_of("w")`:                            6,
+		`$image.substring(0, 6)`:                         "gcr.io",
+		`$image.substring($image.index_of(":") + 1)`:     "v1.2.3",
+		`$image.substring(-6)`:                           "v1.2.3",
+		`$image.substring(7, -7)`:                        "project/app",
+		`$name.substring(1, 5)`:                          "éllo",
+		`$name.substring(3, 3)`:                          "",
+		`$__pad_left("7", 3, "0")`:                       "007",
+		`$__pad_left("7", 3)`:                            "  7",
+		`$__pad_right("ab", 4, ".")`:                     "ab..",
+		`$__pad_right("abcdef", 4)`:                      "abcdef",
+		`$name.pad_left(12, "*")`:                        "*héllo wörld",
+		`$__repeat("ab", 3)`:                             "ababab",
+		`$__repeat("ab", 0)`:                             "",
+		`$__repeat("", 100000000000)`:                    "",
+		`$try($__repeat("ab", 100000000000), "x")`:       "x",
+		`$image.trim_prefix("gcr.io/")`:                  "project/app:v1.2.3",
+		`$image.trim_prefix("docker.io/")`:               "gcr.io/project/app:v1.2.3",
+		`$host.trim_suffix(".local")`:                    "db",
+		`$__title("hello WORLD")`:                        "Hello World",
+		`$__title("my-app v2 don't")`:                    "My-App V2 Don't",
+		`$name.title()`:                                  "Héllo Wörld",
+		`$__format("%s:%d", $host, $port)`:               "db.local:5432",
+		`$__format("%-10s|%5d|%05d", "a", 1, 2)`:         "a         |    1|00002",
+		`$__format("%.2f %g %.1f", 3.14159, 1.5, 2)`:     "3.14 1.5 2.0",
+		`$__format("%q %t %x", "a", $port > 1, "hi")`:    `"a" true 6869`,
+		`$__format("%v %v %v", [1, 2.0], {"a": 1}, 1.0)`: `[1, 2.0] {"a": 1} 1.0`,
+		`$__format("100%%")`:                             "100%",
+		`$__format("no verbs")`:                          "no verbs",
+	}
+	for expr, expected := range cases {
+		val, err := evalStrings(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_string_functions_fail(c *C) {
+	cases := map[string]string{
+		`$image.has_prefix(1)`:          "Expecting string argument in call to strings.HasPrefix, but got integer",
+		`$image.index_of()`:             "Expecting 2 argument\\(s\\) in call to 'index_of', got 1",
+		`$image.substring(100)`:         "Index '100' out of range in substring call \\(len: 25\\)",
+		`$image.substring(-100)`:        "Index '-100' out of range in substring call \\(len: 25\\)",
+		`$image.substring(5, 2)`:        "Start index '5' is greater than end index '2' in substring call",
+		`$image.substring("a")`:         "Expecting integer argument in substring call, but got 'string'",
+		`$image.substring()`:            "Expecting at least 2 argument\\(s\\) \\(but not more than 3\\) in call to 'substring', got 1",
+		`$host.pad_left(10, "ab")`:      `Expecting single character padding in pad_left call, but got 'ab'`,
+		`$host.pad_right("10")`:         "Expecting integer argument in pad_right call, but got 'string'",
+		`$host.repeat(-1)`:              "Expecting positive count in repeat call, but got '-1'",
+		`$host.repeat(100000000000)`:    "Expecting result of at most 1048576 bytes in repeat call, but got count '100000000000'",
+		`$host.pad_left(100000000000)`:  "Expecting length of at most 1048576 in pad_left call, but got '100000000000'",
+		`$host.pad_right(2000000, "*")`: "Expecting length of at most 1048576 in pad_right call, but got '2000000'",
+		`$__format()`:                   "Expecting at least 1 argument\\(s\\) in call to 'format', got 0",
+		`$__format(1)`:                  "Expecting string format in format call, but got 'integer'",
+		`$__format("%d", $host)`:        "Expecting integer argument for '%d' in format call, but got 'string'",
+		`$__format("%s", $port)`:        "Expecting string argument for '%s' in format call, but got 'integer'",
+		`$__format("%.1f", "a")`:        "Expecting integer or float argument for '%.1f' in format call, but got 'string'",
+		`$__format("%s:%d", $host)`:     "Missing argument for '%d' in format call",
+		`$__format("%s", $host, $port)`: "Expecting 1 argument\\(s\\) for format string '%s' in format call, got 2",
+		`$__format("%z", 1)`:            "Invalid format verb at '%z' in format call",
+		`$__format("50%")`:              "Invalid format verb at '%' in format call",
+	}
+	for expr, expected := range cases {
+		_, err := evalStrings(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Times are represented as RFC 3339 strings in UTC (e.g.
+// "2017-07-14T02:40:00Z"), which sort in chronological order. Functions
+// that take a time also accept UNIX timestamps, as returned by timestamp.
+const scriptTimeLayout = time.RFC3339Nano
+
+// Layouts that can be referred to by name in format_time and parse_time.
+// Other layouts are interpreted using Go's reference time, "Mon Jan 2
+// 15:04:05 MST 2006".
+var namedTimeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+var unixTimestampRegex = regexp.MustCompile(`^-?[0-9]+$`)
+var daysDurationRegex = regexp.MustCompile(`^([-+]?)([0-9]+)d(.*)$`)
+
+func liftTime(t time.Time) Script {
+	return LiftString(t.UTC().Format(scriptTimeLayout))
+}
+
+func builtinExpectTimeArg(funcName string, arg Script) (time.Time, error) {
+	if IsIntegerAtom(arg) {
+		return time.Unix(int64(ExpectIntegerAtom(arg)), 0).UTC(), nil
+	}
+	if !IsStringAtom(arg) {
+		return time.Time{}, fmt.Errorf("Expecting string or integer time argument in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	str := ExpectStringAtom(arg)
+	if unixTimestampRegex.MatchString(str) {
+		seconds, err := strconv.ParseInt(str, 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+	}
+	t, err := time.Parse(scriptTimeLayout, str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Expecting RFC 3339 time or UNIX timestamp in %s call, but got '%s'", funcName, str)
+	}
+	return t, nil
+}
+
+func builtinExpectLayoutArg(funcName string, arg Script) (string, error) {
+	if !IsStringAtom(arg) {
+		return "", fmt.Errorf("Expecting string layout in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	layout := ExpectStringAtom(arg)
+	if named, ok := namedTimeLayouts[layout]; ok {
+		return named, nil
+	}
+	return layout, nil
+}
+
+// Returns the time zone in the optional argument at index ix, or UTC.
+func builtinExpectTimeZoneArg(funcName string, inputValues []Script, ix int) (*time.Location, error) {
+	if len(inputValues) <= ix {
+		return time.UTC, nil
+	}
+	arg := inputValues[ix]
+	if !IsStringAtom(arg) {
+		return nil, fmt.Errorf("Expecting string time zone in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	name := ExpectStringAtom(arg)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown time zone '%s' in %s call", name, funcName)
+	}
+	return loc, nil
+}
+
+// Like time.ParseDuration, but also accepts a number of days (e.g. "30d" or
+// "1d12h"), where a day is always 24 hours.
+func parseScriptDuration(funcName, str string) (time.Duration, error) {
+	invalid := fmt.Errorf("Invalid duration '%s' in %s call", str, funcName)
+	match := daysDurationRegex.FindStringSubmatch(str)
+	if match == nil {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, invalid
+		}
+		return d, nil
+	}
+	sign, rest := match[1], match[3]
+	days, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil || days > math.MaxInt64/int64(24*time.Hour) {
+		return 0, invalid
+	}
+	d := time.Duration(days) * 24 * time.Hour
+	if rest != "" {
+		if rest[0] == '-' || rest[0] == '+' {
+			return 0, invalid
+		}
+		hours, err := time.ParseDuration(rest)
+		if err != nil || hours > math.MaxInt64-d {
+			return 0, invalid
+		}
+		d += hours
+	}
+	if sign == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+func builtinExpectDurationArg(funcName string, arg Script) (time.Duration, error) {
+	if !IsStringAtom(arg) {
+		return 0, fmt.Errorf("Expecting string duration in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	return parseScriptDuration(funcName, ExpectStringAtom(arg))
+}
+
+func builtinNow(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(0, "now", inputValues); err != nil {
+		return nil, err
+	}
+	now, err := env.GetEvalPolicy().Now("now")
+	if err != nil {
+		return nil, err
+	}
+	return liftTime(now), nil
+}
+
+func builtinFormatTime(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 2 || len(inputValues) > 3 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+			2, "format_time", len(inputValues))
+	}
+	t, err := builtinExpectTimeArg("format_time", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	layout, err := builtinExpectLayoutArg("format_time", inputValues[1])
+	if err != nil {
+		return nil, err
+	}
+	loc, err := builtinExpectTimeZoneArg("format_time", inputValues, 2)
+	if err != nil {
+		return nil, err
+	}
+	return LiftString(t.In(loc).Format(layout)), nil
+}
+
+func builtinParseTime(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if len(inputValues) < 2 || len(inputValues) > 3 {
+		return nil, fmt.Errorf("Expecting at least %d argument(s) (but not more than 3) in call to '%s', got %d",
+			2, "parse_time", len(inputValues))
+	}
+	valueArg := inputValues[0]
+	if !IsStringAtom(valueArg) {
+		return nil, fmt.Errorf("Expecting string argument in parse_time call, but got '%s'", valueArg.Type().Name())
+	}
+	layout, err := builtinExpectLayoutArg("parse_time", inputValues[1])
+	if err != nil {
+		return nil, err
+	}
+	loc, err := builtinExpectTimeZoneArg("parse_time", inputValues, 2)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.ParseInLocation(layout, ExpectStringAtom(valueArg), loc)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't parse time in parse_time call: %s", err.Error())
+	}
+	return liftTime(t), nil
+}
+
+func builtinAddDuration(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "add_duration", inputValues); err != nil {
+		return nil, err
+	}
+	t, err := builtinExpectTimeArg("add_duration", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDurationArg("add_duration", inputValues[1])
+	if err != nil {
+		return nil, err
+	}
+	return liftTime(t.Add(d)), nil
+}
+
+func builtinDurationSeconds(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(1, "duration_seconds", inputValues); err != nil {
+		return nil, err
+	}
+	d, err := builtinExpectDurationArg("duration_seconds", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	return LiftInteger(int(d / time.Second)), nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func evalTime(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"issued": LiftString("2017-07-14T02:40:00Z"),
+		"unix":   LiftInteger(1500000000),
+	})
+	policy := NewEvalPolicy()
+	policy.Clock = FixedClock(time.Date(2018, 3, 1, 12, 30, 0, 0, time.UTC))
+	env.SetEvalPolicy(policy)
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_time_functions(c *C) {
+	cases := map[string]interface{}{
+		`$__now()`:                                                                          "2018-03-01T12:30:00Z",
+		`$__now().add_duration("8760h")`:                                                    "2019-03-01T12:30:00Z",
+		`$__now().add_duration("90d")`:                                                      "2018-05-30T12:30:00Z",
+		`$__now().add_duration("-1d12h")`:                                                   "2018-02-28T00:30:00Z",
+		`$__now().add_duration("1.5s")`:                                                     "2018-03-01T12:30:01.5Z",
+		`$issued.format_time("DateOnly")`:                                                   "2017-07-14",
+		`$issued.format_time("RFC1123")`:                                                    "Fri, 14 Jul 2017 02:40:00 UTC",
+		`$issued.format_time("2006-01-02 15:04 MST", "Europe/Amsterdam")`:                   "2017-07-14 04:40 CEST",
+		`$issued.format_time("Kitchen", "America/New_York")`:                                "10:40PM",
+		`$unix.format_time("RFC3339")`:                                                      "2017-07-14T02:40:00Z",
+		`$__timestamp().format_time("RFC3339")`:                                             "2018-03-01T12:30:00Z",
+		`$__parse_time("14/07/2017 04:40", "02/01/2006 15:04", "Europe/Amsterdam")`:         "2017-07-14T02:40:00Z",
+		`$__parse_time("14/07/2017 04:40", "02/01/2006 15:04")`:                             "2017-07-14T04:40:00Z",
+		`$__parse_time("2017-07-14T04:40:00+02:00", "RFC3339")`:                             "2017-07-14T02:40:00Z",
+		`$__parse_time("Fri, 14 Jul 2017 02:40:00 UTC", "RFC1123").format_time("DateTime")`: "2017-07-14 02:40:00",
+		`$__duration_seconds("1h30m")`:                                                      5400,
+		`$__duration_seconds("30d")`:                                                        2592000,
+		`$__duration_seconds("-90s")`:                                                       -90,
+	}
+	for expr, expected := range cases {
+		val, err := evalTime(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_time_functions_fail(c *C) {
+	cases := map[string]string{
+		`$__now(1)`:                              "Expecting 0 argument\\(s\\) in call to 'now', got 1",
+		`$issued.format_time()`:                  "Expecting at least 2 argument\\(s\\) \\(but not more than 3\\) in call to 'format_time', got 1",
+		`$issued.format_time(1)`:                 "Expecting string layout in format_time call, but got 'integer'",
+		`$issued.format_time("RFC3339", "Mars")`: "Unknown time zone 'Mars' in format_time call",
+		`$issued.format_time("RFC3339", 1)`:      "Expecting string time zone in format_time call, but got 'integer'",
+		`$__format_time("yesterday", "RFC3339")`: "Expecting RFC 3339 time or UNIX timestamp in format_time call, but got 'yesterday'",
+		`$__format_time([], "RFC3339")`:          "Expecting string or integer time argument in format_time call, but got 'list'",
+		`$__parse_time("2017", "DateOnly")`:      "Couldn't parse time in parse_time call: .*",
+		`$__parse_time(2017, "DateOnly")`:        "Expecting string argument in parse_time call, but got 'integer'",
+		`$issued.add_duration("1 hour")`:         "Invalid duration '1 hour' in add_duration call",
+		`$issued.add_duration("1d-2h")`:          "Invalid duration '1d-2h' in add_duration call",
+		`$issued.add_duration(3600)`:             "Expecting string duration in add_duration call, but got 'integer'",
+		`$__duration_seconds("")`:                "Invalid duration '' in duration_seconds call",
+		`$__duration_seconds("106752d")`:         "Invalid duration '106752d' in duration_seconds call",
+		`$__duration_seconds("106751d24h")`:      "Invalid duration '106751d24h' in duration_seconds call",
+	}
+	for expr, expected := range cases {
+		_, err := evalTime(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_now_uses_evaluation_policy(c *C) {
+	val, err := evalWithPolicy(NewSandboxEvalPolicy(), `$__now()`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "1970-01-01T00:00:00Z")
+	policy := NewEvalPolicy()
+	policy.DisableClock = true
+	_, err = evalWithPolicy(policy, `$__now()`)
+	c.Assert(err, ErrorMatches, ".*Using the clock in 'now' is not allowed by the evaluation policy")
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+
+	"github.com/ankyra/escape-core/parsers"
+)
+
+func builtinExpectVersionArg(funcName string, arg Script) (*parsers.SemanticVersion, error) {
+	if !IsStringAtom(arg) {
+		return nil, fmt.Errorf("Expecting string argument in %s call, but got '%s'", funcName, arg.Type().Name())
+	}
+	version, err := parsers.ParseSemanticVersion(ExpectStringAtom(arg))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid version '%s' in %s call", ExpectStringAtom(arg), funcName)
+	}
+	return version, nil
+}
+
+func builtinExpectVersionArgs(funcName string, expected int, inputValues []Script) ([]*parsers.SemanticVersion, error) {
+	if err := builtinArgCheck(expected, funcName, inputValues); err != nil {
+		return nil, err
+	}
+	result := []*parsers.SemanticVersion{}
+	for _, arg := range inputValues {
+		version, err := builtinExpectVersionArg(funcName, arg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, version)
+	}
+	return result, nil
+}
+
+func builtinVersionCompare(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	versions, err := builtinExpectVersionArgs("version_compare", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	return LiftInteger(versions[0].Compare(versions[1])), nil
+}
+
+func builtinVersionGT(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	versions, err := builtinExpectVersionArgs("version_gt", 2, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	return LiftBool(versions[0].Compare(versions[1]) > 0), nil
+}
+
+func builtinVersionParts(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	versions, err := builtinExpectVersionArgs("version_parts", 1, inputValues)
+	if err != nil {
+		return nil, err
+	}
+	result := []Script{}
+	for _, part := range versions[0].Parts() {
+		result = append(result, LiftInteger(part))
+	}
+	return LiftList(result), nil
+}
+
+func builtinBumpVersion(funcName string, part int) scriptFuncType {
+	return func(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+		versions, err := builtinExpectVersionArgs(funcName, 1, inputValues)
+		if err != nil {
+			return nil, err
+		}
+		if err := versions[0].IncrementPart(part); err != nil {
+			return nil, fmt.Errorf("Couldn't increment version in %s call: %s", funcName, err.Error())
+		}
+		return LiftString(versions[0].ToString()), nil
+	}
+}
+
+func builtinVersionMatches(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	if err := builtinArgCheck(2, "version_matches", inputValues); err != nil {
+		return nil, err
+	}
+	version, err := builtinExpectVersionArg("version_matches", inputValues[0])
+	if err != nil {
+		return nil, err
+	}
+	queryArg := inputValues[1]
+	if !IsStringAtom(queryArg) {
+		return nil, fmt.Errorf("Expecting string query in version_matches call, but got '%s'", queryArg.Type().Name())
+	}
+	ok, err := version.Matches(ExpectStringAtom(queryArg))
+	if err != nil {
+		return nil, fmt.Errorf("%s in version_matches call", err.Error())
+	}
+	return LiftBool(ok), nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalVersion(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"dep": LiftDict(map[string]Script{
+			"version": LiftString("2.1.0"),
+		}),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Builtin_version_functions(c *C) {
+	cases := map[string]interface{}{
+		`$dep.version.version_compare("2.1.0")`:                         0,
+		`$dep.version.version_compare("2.10.0")`:                        -1,
+		`$dep.version.version_compare("1.99")`:                          1,
+		`$dep.version.version_gt("2.0.9")`:                              true,
+		`$dep.version.version_gt("2.1.0")`:                              false,
+		`$__version_gt("10.0", "9.0")`:                                  true,
+		`$dep.version.version_parts()`:                                  []interface{}{2, 1, 0},
+		`$dep.version.version_parts()[0] >= 2`:                          true,
+		`$dep.version.bump_major()`:                                     "3.0.0",
+		`$dep.version.bump_minor()`:                                     "2.2.0",
+		`$dep.version.bump_patch()`:                                     "2.1.1",
+		`$__bump_patch("1")`:                                            "1.0.1",
+		`$dep.version.version_matches(">= 2")`:                          true,
+		`$dep.version.version_matches(">= 2, < 2.1")`:                   false,
+		`$dep.version.version_matches("2.1.@")`:                         true,
+		`$if($dep.version.version_matches(">= 2"), "v2.yml", "v1.yml")`: "v2.yml",
+		`$__version_compare("2.0.0", "2")`:                              0,
+		`$__version_matches("2.0.0", "<= 2")`:                           true,
+		`$__version_matches("2.0.0", "== 2")`:                           true,
+		`$__version_matches("2.0.0", "> 2")`:                            false,
+		`$__version_gt("2.0.1", "2")`:                                   true,
+	}
+	for expr, expected := range cases {
+		val, err := evalVersion(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Builtin_version_functions_fail(c *C) {
+	cases := map[string]string{
+		`$dep.version.version_compare("2.x")`:    "Invalid version '2.x' in version_compare call",
+		`$__bump_major("1.2.@")`:                 "Invalid version '1.2.@' in bump_major call",
+		`$__version_parts(1)`:                    "Expecting string argument in version_parts call, but got 'integer'",
+		`$dep.version.version_gt()`:              "Expecting 2 argument\\(s\\) in call to 'version_gt', got 1",
+		`$dep.version.version_matches("> a")`:    "Invalid version constraint '> a' in version_matches call",
+		`$dep.version.version_matches("my-tag")`: "Invalid version constraint 'my-tag' in version_matches call",
+		`$dep.version.version_matches(2)`:        "Expecting string query in version_matches call, but got 'integer'",
+	}
+	for expr, expected := range cases {
+		_, err := evalVersion(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"sync"
+)
+
+// A concurrency-safe cache of parsed scripts, keyed on their source text.
+// Evaluating a Script never modifies it, so the same parsed Script can be
+// shared between goroutines. Parse errors are cached as well.
+type ScriptCache struct {
+	sync.RWMutex
+	maxSize int
+	scripts map[string]*cachedScript
+}
+
+type cachedScript struct {
+	Script Script
+	Error  error
+}
+
+const DefaultScriptCacheSize = 16384
+
+// The cache used by ParseScriptCached, ParseAndEvalToGoValue and
+// ParseAndEvalToString.
+var DefaultScriptCache = NewScriptCache(DefaultScriptCacheSize)
+
+// Creates a cache that holds at most maxSize scripts. An arbitrary script is
+// evicted when the cache is full.
+func NewScriptCache(maxSize int) *ScriptCache {
+	return &ScriptCache{
+		maxSize: maxSize,
+		scripts: map[string]*cachedScript{},
+	}
+}
+
+// Parses the script, or returns the result of a previous parse of the same
+// string.
+func (c *ScriptCache) Parse(str string) (Script, error) {
+	c.RLock()
+	cached, ok := c.scripts[str]
+	c.RUnlock()
+	if ok {
+		return cached.Script, cached.Error
+	}
+	script, err := ParseScript(str)
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.scripts[str]; !ok && len(c.scripts) >= c.maxSize {
+		for key := range c.scripts {
+			delete(c.scripts, key)
+			break
+		}
+	}
+	c.scripts[str] = &cachedScript{
+		Script: script,
+		Error:  err,
+	}
+	return script, err
+}
+
+func (c *ScriptCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.scripts)
+}
+
+func (c *ScriptCache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.scripts = map[string]*cachedScript{}
+}
+
+func ParseScriptCached(str string) (Script, error) {
+	return DefaultScriptCache.Parse(str)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *parserSuite) Test_ScriptCache_Parse(c *C) {
+	cache := NewScriptCache(10)
+	first, err := cache.Parse(`$x.upper()`)
+	c.Assert(err, IsNil)
+	second, err := cache.Parse(`$x.upper()`)
+	c.Assert(err, IsNil)
+	c.Assert(second == first, Equals, true, Commentf("Expecting the cached script"))
+	c.Assert(first.Equals(ShouldParse(`$x.upper()`)), Equals, true)
+	c.Assert(cache.Len(), Equals, 1)
+}
+
+func (s *parserSuite) Test_ScriptCache_Parse_caches_errors(c *C) {
+	cache := NewScriptCache(10)
+	_, err := cache.Parse(`$x.upper(`)
+	c.Assert(err, Not(IsNil))
+	_, err2 := cache.Parse(`$x.upper(`)
+	c.Assert(err2, Equals, err)
+	_, parseErr := ParseScript(`$x.upper(`)
+	c.Assert(err.Error(), Equals, parseErr.Error())
+}
+
+func (s *parserSuite) Test_ScriptCache_evicts_when_full(c *C) {
+	cache := NewScriptCache(3)
+	for i := 0; i < 10; i++ {
+		_, err := cache.Parse(fmt.Sprintf("$x.concat(%d)", i))
+		c.Assert(err, IsNil)
+		c.Assert(cache.Len() <= 3, Equals, true)
+	}
+	c.Assert(cache.Len(), Equals, 3)
+	cache.Clear()
+	c.Assert(cache.Len(), Equals, 0)
+}
+
+func (s *parserSuite) Test_ScriptCache_is_safe_for_concurrent_use(c *C) {
+	cache := NewScriptCache(50)
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{"x": LiftInteger(1)})
+	wg := sync.WaitGroup{}
+	errors := make(chan error, 800)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				parsed, err := cache.Parse(fmt.Sprintf("$x + %d", i))
+				if err == nil {
+					_, err = EvalToGoValue(parsed, env)
+				}
+				if err != nil {
+					errors <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		c.Assert(err, IsNil)
+	}
+	c.Assert(cache.Len(), Equals, 50)
+}
+
+func (s *parserSuite) Test_ParseAndEvalToGoValue_uses_the_default_cache(c *C) {
+	DefaultScriptCache.Clear()
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{"x": LiftString("abc")})
+	val, err := ParseAndEvalToGoValue(`$x.upper()`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "ABC")
+	c.Assert(DefaultScriptCache.Len(), Equals, 1)
+	str, err := ParseAndEvalToString(`$x.upper()`, env)
+	c.Assert(err, IsNil)
+	c.Assert(str, Equals, "ABC")
+	c.Assert(DefaultScriptCache.Len(), Equals, 1)
+}
+
+// A large environment: many variables with expressions that are evaluated
+// over and over again, as happens when an inventory evaluates the same
+// releases for every request.
+func newBenchmarkEnvironment(n int) (*ScriptEnvironment, []string) {
+	globals := map[string]Script{}
+	exprs := []string{}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("var%d", i)
+		globals[name] = LiftDict(map[string]Script{
+			"outputs": LiftDict(map[string]Script{
+				"cluster": LiftString(fmt.Sprintf("projects/project-%d/zones/europe-west1-b/clusters/cluster-%d", i, i)),
+			}),
+		})
+		exprs = append(exprs, fmt.Sprintf(`$%s.outputs.cluster.split("/")[1].concat("-", $%s.outputs.cluster.split("/")[5]).upper()`, name, name))
+	}
+	return NewScriptEnvironmentWithGlobals(globals), exprs
+}
+
+func BenchmarkParseAndEval_uncached(b *testing.B) {
+	env, exprs := newBenchmarkEnvironment(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, expr := range exprs {
+			parsed, err := ParseScript(expr)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := EvalToGoValue(parsed, env); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseAndEval_cached(b *testing.B) {
+	env, exprs := newBenchmarkEnvironment(1000)
+	cache := NewScriptCache(DefaultScriptCacheSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, expr := range exprs {
+			parsed, err := cache.Parse(expr)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := EvalToGoValue(parsed, env); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseAndEval_cached_parallel(b *testing.B) {
+	env, exprs := newBenchmarkEnvironment(1000)
+	cache := NewScriptCache(DefaultScriptCacheSize)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for _, expr := range exprs {
+				parsed, err := cache.Parse(expr)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := EvalToGoValue(parsed, env); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
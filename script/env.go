@@ -16,7 +16,10 @@ limitations under the License.
 
 package script
 
-import ()
+import (
+	"fmt"
+	"strings"
+)
 
 type ScriptEnvironment map[string]Script
 
@@ -30,14 +33,216 @@ func NewScriptEnvironmentFromMap(m map[string]Script) *ScriptEnvironment {
 }
 
 func NewScriptEnvironmentWithGlobals(globals map[string]Script) *ScriptEnvironment {
+	return newScriptEnvironmentWithFunctions(globals, Stdlib)
+}
+
+// Creates an environment where only the functions in the registry are
+// available, instead of the standard library.
+func NewScriptEnvironmentWithRegistry(globals map[string]Script, registry *FunctionRegistry) *ScriptEnvironment {
+	return newScriptEnvironmentWithFunctions(globals, registry.functions)
+}
+
+func newScriptEnvironmentWithFunctions(globals map[string]Script, functions []StdlibFunc) *ScriptEnvironment {
 	result := ScriptEnvironment{}
 	if globals == nil {
 		globals = map[string]Script{}
 	}
-	for _, f := range Stdlib {
+	for _, f := range functions {
 		globals["__"+f.Id] = f.Func
 	}
 	globalsDict := LiftDict(globals)
 	result["$"] = globalsDict
 	return &result
 }
+
+// Returns a copy of the environment in which the bindings are added to the
+// globals. Used to scope lambda arguments and let bindings; the original
+// environment is left untouched.
+func (s *ScriptEnvironment) newChildEnvironment(bindings map[string]Script) *ScriptEnvironment {
+	if s == nil {
+		s = NewScriptEnvironment()
+	}
+	newEnv := map[string]Script{}
+	for key, val := range *s {
+		newEnv[key] = val
+	}
+	globals, found := newEnv["$"]
+	if !found {
+		globals = LiftDict(map[string]Script{})
+	}
+	if _, found := newEnv[rootGlobalsKey]; !found {
+		newEnv[rootGlobalsKey] = globals
+	}
+	newGlobals := map[string]Script{}
+	for key, val := range ExpectDictAtom(globals) {
+		newGlobals[key] = val
+	}
+	for key, val := range bindings {
+		newGlobals[key] = val
+	}
+	newEnv["$"] = LiftDict(newGlobals)
+	return NewScriptEnvironmentFromMap(newEnv)
+}
+
+// Returns a copy of the environment that uses the globals of the other
+// environment. The evaluation policy, tracer and call depth are kept.
+func (s *ScriptEnvironment) withGlobalsFrom(other *ScriptEnvironment) *ScriptEnvironment {
+	newEnv := map[string]Script{}
+	if s != nil {
+		for key, val := range *s {
+			newEnv[key] = val
+		}
+	}
+	if globals, ok := (*other)["$"]; ok {
+		newEnv["$"] = globals
+	}
+	return NewScriptEnvironmentFromMap(newEnv)
+}
+
+const callDepthKey = "__call_depth"
+
+const rootGlobalsKey = "__root_globals"
+
+// Returns the globals the environment was created with. Unlike the globals
+// in "$" these can't be shadowed by let bindings and lambda arguments.
+func (s *ScriptEnvironment) rootGlobals() map[string]Script {
+	if s == nil {
+		return map[string]Script{}
+	}
+	globals, ok := (*s)[rootGlobalsKey]
+	if !ok {
+		globals, ok = (*s)["$"]
+	}
+	if !ok || !IsDictAtom(globals) {
+		return map[string]Script{}
+	}
+	return ExpectDictAtom(globals)
+}
+
+// Returns the depth of a new lambda call, or an error if that would exceed
+// the evaluation policy's maximum call depth.
+func (s *ScriptEnvironment) enterCall() (int, error) {
+	depth := 1
+	if s != nil {
+		if current, ok := (*s)[callDepthKey]; ok && IsIntegerAtom(current) {
+			depth = ExpectIntegerAtom(current) + 1
+		}
+	}
+	max := s.GetEvalPolicy().GetMaxCallDepth()
+	if depth > max {
+		return 0, fmt.Errorf("Maximum call depth of %d exceeded in lambda call (infinite recursion?)", max)
+	}
+	return depth, nil
+}
+
+// Restricts the builtin functions used by scripts evaluated in this
+// environment. The policy is inherited by the environments that are created
+// when lambdas are applied.
+func (s *ScriptEnvironment) SetEvalPolicy(policy *EvalPolicy) {
+	(*s)[evalPolicyKey] = policy
+}
+
+// Returns the evaluation policy, or an unrestricted policy if none was set.
+func (s *ScriptEnvironment) GetEvalPolicy() *EvalPolicy {
+	if s != nil {
+		if policy, ok := (*s)[evalPolicyKey].(*EvalPolicy); ok && policy != nil {
+			return policy
+		}
+	}
+	return NewEvalPolicy()
+}
+
+// Sets the secret that is used to derive the seeded values returned by
+// random_string and password. Without a secret these values could be
+// recomputed by anyone who knows the seed and the deployment's name.
+func (s *ScriptEnvironment) SetSecret(secret string) {
+	(*s)[secretKey] = &environmentSecret{key: []byte(secret)}
+}
+
+const secretKey = "__secret"
+
+// The keys that are used to store the policy, tracer, secret, etc. They can't
+// be read by scripts.
+func isReservedEnvironmentKey(key string) bool {
+	return strings.HasPrefix(key, "__")
+}
+
+// Let bindings and lambda arguments can't use reserved names, so that they
+// can't be mistaken for (or shadow) the values stored under them and the
+// standard library functions.
+func checkBindableName(name string) error {
+	if isReservedEnvironmentKey(name) {
+		return fmt.Errorf("Can't bind reserved name '%s'", name)
+	}
+	return nil
+}
+
+// The secret is stored in the ScriptEnvironment, which means it needs to be a
+// Script, but it can't be referenced from scripts.
+type environmentSecret struct {
+	key []byte
+}
+
+func (e *environmentSecret) Eval(env *ScriptEnvironment) (Script, error) {
+	return e, nil
+}
+func (e *environmentSecret) Value() (interface{}, error) {
+	return nil, fmt.Errorf("The environment's secret can not be converted to a Go value")
+}
+func (e *environmentSecret) Type() ValueType {
+	return NewType("secret")
+}
+func (e *environmentSecret) Equals(s2 Script) bool {
+	e2, ok := s2.(*environmentSecret)
+	return ok && e == e2
+}
+
+func (s *ScriptEnvironment) getSecret() []byte {
+	if s == nil {
+		return nil
+	}
+	secret, _ := (*s)[secretKey].(*environmentSecret)
+	if secret == nil {
+		return nil
+	}
+	return secret.key
+}
+
+// Records the function applications performed by scripts evaluated in this
+// environment. The sensitive values in the environment are masked in the
+// trace. See Tracer.
+func (s *ScriptEnvironment) SetTracer(tracer *Tracer) {
+	(*s)[tracerKey] = tracer
+	for _, value := range s.getSensitiveValues() {
+		tracer.maskScript(value)
+	}
+}
+
+const sensitiveValuesKey = "__sensitive"
+
+// Marks the value (e.g. the value of a sensitive input variable) as
+// sensitive, so that it's masked by the tracer.
+func (s *ScriptEnvironment) AddSensitiveValue(value Script) {
+	values := append(s.getSensitiveValues(), value)
+	(*s)[sensitiveValuesKey] = LiftList(values)
+	if tracer := s.GetTracer(); tracer != nil {
+		tracer.maskScript(value)
+	}
+}
+
+func (s *ScriptEnvironment) getSensitiveValues() []Script {
+	values, ok := (*s)[sensitiveValuesKey]
+	if !ok || !IsListAtom(values) {
+		return []Script{}
+	}
+	return ExpectListAtom(values)
+}
+
+// Returns the tracer, or nil if tracing is disabled.
+func (s *ScriptEnvironment) GetTracer() *Tracer {
+	if s == nil {
+		return nil
+	}
+	tracer, _ := (*s)[tracerKey].(*Tracer)
+	return tracer
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"strings"
+)
+
+// An EvalError is returned when a script fails to evaluate, either because
+// one of its function applications failed or because it called the 'error'
+// builtin. It points at the expression that raised the error.
+type EvalError struct {
+	// A description of what went wrong.
+	Message string
+
+	// The innermost function application that failed. It can be rendered as
+	// source code using Print.
+	Expression Script
+}
+
+// Wraps errors in an EvalError for the expression, unless they are already
+// EvalErrors, so that the innermost failing expression is kept.
+func newEvalError(expression Script, err error) error {
+	if _, ok := err.(*EvalError); ok {
+		return err
+	}
+	return &EvalError{
+		Message:    err.Error(),
+		Expression: expression,
+	}
+}
+
+// Failed lookups of stdlib functions (e.g. the "__unknown" in "$x.unknown()")
+// are attributed to the call, because the lookup can't be printed by itself.
+func isFunctionLookup(s Script) bool {
+	key, ok := globalLookupKey(s)
+	return ok && strings.HasPrefix(key, "__")
+}
+
+func (e *EvalError) Error() string {
+	return e.Message
+}
+
+// Returns the failing expression as source code.
+func (e *EvalError) Source() string {
+	if e.Expression == nil {
+		return ""
+	}
+	return Print(e.Expression)
+}
+
+// Returns the error as a dictionary with a "message" and an "expression"
+// field, which is how errors are exposed to the fallback of a try expression.
+func (e *EvalError) toDict() Script {
+	return LiftDict(map[string]Script{
+		"message":    LiftString(e.Message),
+		"expression": LiftString(e.Source()),
+	})
+}
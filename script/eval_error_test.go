@@ -0,0 +1,96 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalTry(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"config": LiftDict(map[string]Script{
+			"name":  LiftString("app"),
+			"ports": LiftList([]Script{LiftInteger(80)}),
+		}),
+		"replicas": LiftInteger(0),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Eval_Try(c *C) {
+	cases := map[string]interface{}{
+		`$try($config.name, "default")`:                                   "app",
+		`$try($config.missing, "default")`:                                "default",
+		`$try($config.ports[3], 443)`:                                     443,
+		`$try($__read_file("does-not-exist"), "")`:                        "",
+		`$try($__error("boom"), "recovered")`:                             "recovered",
+		`$try($config.ports[3], $error.message)`:                          "Index '3' out of range (len: 1)",
+		`$try($config.ports[0:5], [])`:                                    []interface{}{},
+		`$try($config.ports[2:], $error.message)`:                         "Index '2' out of range in list slice call (len: 1)",
+		`$try($config.ports[0:-2], $error.message)`:                       "Index '-2' out of range in list slice call (len: 1)",
+		`$try($__list_slice([1, 2, 3], 2, 1), $error.message)`:            "Start index '2' is greater than end index '1' in list slice call",
+		`$try($__list_slice([1, 2, 3], -1), $error.message)`:              "Index '-1' out of range in list slice call (len: 3)",
+		`$config.ports[1:]`:                                               []interface{}{},
+		`$config.ports[0:1]`:                                              []interface{}{80},
+		`$try($config.name.upper().missing(), $error.expression)`:         `$config.name.upper().missing()`,
+		`$try($__error("boom"), $error.expression)`:                       `$__error("boom")`,
+		`$try($try($__error("a"), $__error("b")), $error.message)`:        "b",
+		`$try(1, $__error("not evaluated"))`:                              1,
+		`$config.ports.map($func(p) { $try($config.missing, $p + 1) })`:   []interface{}{81},
+		`$try($func loop(n) { $loop($n) }(1), "too deep")`:                "too deep",
+		`$try($if($replicas > 0, $replicas, $__error("no replicas")), 1)`: 1,
+	}
+	for expr, expected := range cases {
+		val, err := evalTry(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Eval_Error(c *C) {
+	cases := map[string]string{
+		`$__error("Invalid number of replicas")`:                            "Invalid number of replicas",
+		`$if($replicas > 0, $replicas, $__error("replicas should be > 0"))`: "replicas should be > 0",
+		`$config.name.error()`:                                              "app",
+		`$try($__error("a"), $__error($error.message.concat("b")))`:         "ab",
+		`$__error(1)`: "Expecting string argument in error call, but got 'integer'",
+		`$__error()`:  "Expecting 1 argument\\(s\\) in call to 'error', got 0",
+	}
+	for expr, expected := range cases {
+		_, err := evalTry(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_EvalError_carries_originating_expression(c *C) {
+	cases := map[string]string{
+		`$config.name.concat($config.missing)`:                       `$config.missing`,
+		`$if($replicas > 0, 1, $__error("no replicas")).concat("x")`: `$__error("no replicas")`,
+		`$config.ports.map($func(x) { $x.upper() })`:                 `$x.upper()`,
+		`$let(f = $func(x) { $x[5] }) { $f($config.ports) }`:         `$x[5]`,
+	}
+	for expr, expected := range cases {
+		_, err := evalTry(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		evalErr, ok := err.(*EvalError)
+		c.Assert(ok, Equals, true, Commentf("Expression '%s'", expr))
+		c.Assert(evalErr.Source(), Equals, expected, Commentf("Expression '%s'", expr))
+	}
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package script
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
+
+	"github.com/ankyra/escape-core/util"
 )
 
 type scriptFuncType func(*ScriptEnvironment, []Script) (Script, error)
@@ -40,7 +44,11 @@ func Lift(val interface{}) (Script, error) {
 	case bool:
 		return LiftBool(val.(bool)), nil
 	case float64:
-		return LiftInteger(int(val.(float64))), nil
+		return liftNumber(val.(float64)), nil
+	case float32:
+		return liftNumber(float64(val.(float32))), nil
+	case json.Number:
+		return liftJsonNumber(val.(json.Number))
 	case int:
 		return LiftInteger(val.(int)), nil
 	case Script:
@@ -63,6 +71,8 @@ func Lift(val interface{}) (Script, error) {
 		return LiftGoFunc(val), nil
 	case func(string) string:
 		return LiftGoFunc(val), nil
+	case func(string, string) bool:
+		return LiftGoFunc(val), nil
 	case func(string, string) []string:
 		return LiftGoFunc(val), nil
 	case func(string, string) string:
@@ -75,6 +85,8 @@ func Lift(val interface{}) (Script, error) {
 		return LiftGoFunc(val), nil
 	case func(int, int) int:
 		return LiftGoFunc(val), nil
+	case func(int, int) (int, error):
+		return LiftGoFunc(val), nil
 	case []string:
 		vals := []Script{}
 		for _, k := range val.([]string) {
@@ -119,6 +131,29 @@ func Lift(val interface{}) (Script, error) {
 	return nil, fmt.Errorf("Couldn't lift value of type '%T': %v", val, val)
 }
 
+// Decoded JSON (e.g. from the deployment state) uses float64 for every
+// number, so whole numbers are lifted to integers. Numbers that are too large
+// to be represented exactly are kept as floats.
+func liftNumber(f float64) Script {
+	if util.IsWholeNumber(f) {
+		return LiftInteger(int(f))
+	}
+	return LiftFloat(f)
+}
+
+// JSON numbers decoded with UseNumber() keep their literal, which makes it
+// possible to tell 1 and 1.0 apart.
+func liftJsonNumber(n json.Number) (Script, error) {
+	if i, err := strconv.Atoi(string(n)); err == nil {
+		return LiftInteger(i), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't lift number '%s': %s", n, err.Error())
+	}
+	return LiftFloat(f), nil
+}
+
 func ShouldLift(v interface{}) Script {
 	result, err := Lift(v)
 	if err != nil {
@@ -203,6 +238,35 @@ func ExpectBoolAtom(s Script) bool {
 	panic("Expecting bool type, got " + s.Type().Name())
 }
 
+/*
+   Null
+*/
+type nullAtom struct{}
+
+// Null is the result of a safe navigation (e.g. "$p?.outputs?.url") that
+// didn't find anything. It can be written as "$null".
+func LiftNull() Script {
+	return &nullAtom{}
+}
+
+func (n *nullAtom) Eval(env *ScriptEnvironment) (Script, error) {
+	return n, nil
+}
+func (n *nullAtom) Value() (interface{}, error) {
+	return nil, nil
+}
+func (n *nullAtom) Type() ValueType {
+	return NewType("null")
+}
+func (n *nullAtom) Equals(s2 Script) bool {
+	return IsNullAtom(s2)
+}
+
+func IsNullAtom(s Script) (ok bool) {
+	_, ok = s.(*nullAtom)
+	return ok
+}
+
 /*
    Integers
 */
@@ -240,6 +304,49 @@ func ExpectIntegerAtom(s Script) int {
 	panic("Expecting integer type, got " + s.Type().Name())
 }
 
+/*
+   Floats
+*/
+type floatAtom struct {
+	Float float64
+}
+
+func LiftFloat(f float64) Script {
+	return &floatAtom{Float: f}
+}
+func (f *floatAtom) Eval(env *ScriptEnvironment) (Script, error) {
+	return f, nil
+}
+func (f *floatAtom) Value() (interface{}, error) {
+	return f.Float, nil
+}
+func (f *floatAtom) Type() ValueType {
+	return NewType("float")
+}
+func (s *floatAtom) Equals(s2 Script) bool {
+	if !s2.Type().IsFloat() {
+		return false
+	}
+	s2Val := ExpectFloatAtom(s2)
+	return s.Float == s2Val
+}
+func IsFloatAtom(s Script) (ok bool) {
+	_, ok = s.(*floatAtom)
+	return ok
+}
+func ExpectFloatAtom(s Script) float64 {
+	if IsFloatAtom(s) {
+		return s.(*floatAtom).Float
+	}
+	panic("Expecting float type, got " + s.Type().Name())
+}
+
+// Formats the float so that it always contains a decimal point and can't be
+// mistaken for an integer, e.g. "2.0", "0.5" or "1.0e+21".
+func FormatFloat(f float64) string {
+	return util.FormatFloat(f)
+}
+
 /*
    Lists
 */
@@ -312,7 +419,15 @@ func LiftDict(d map[string]Script) Script {
 	return &dict{Dict: d}
 }
 func (d *dict) Eval(env *ScriptEnvironment) (Script, error) {
-	return d, nil
+	result := map[string]Script{}
+	for key, val := range d.Dict {
+		evaled, err := val.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = evaled
+	}
+	return LiftDict(result), nil
 }
 func (d *dict) Value() (interface{}, error) {
 	return d.Dict, nil
@@ -384,8 +499,10 @@ func (f *Function) Value() (interface{}, error) {
 func (f *Function) Type() ValueType {
 	return NewType("func")
 }
+// Go functions can't be compared, so functions are only equal to themselves.
 func (s *Function) Equals(s2 Script) bool {
-	return false
+	f2, ok := s2.(*Function)
+	return ok && s == f2
 }
 func IsFunctionAtom(s Script) bool {
 	_, ok := s.(*Function)
@@ -408,8 +525,13 @@ func ExpectFunctionAtom(s Script) scriptFuncType {
    Lambda
 */
 type lambda struct {
+	Name      string
 	Arguments []string
 	Body      Script
+
+	// The environment the lambda was defined in. Set when the lambda is
+	// evaluated, which turns it into a closure.
+	env *ScriptEnvironment
 }
 
 func NewLambda(args []string, body Script) Script {
@@ -419,8 +541,29 @@ func NewLambda(args []string, body Script) Script {
 	}
 }
 
+// A named lambda can refer to itself by its name (e.g. "$fact" in "$func
+// fact(n) { ... }"), which makes it possible to write recursive functions.
+func NewNamedLambda(name string, args []string, body Script) Script {
+	return &lambda{
+		Name:      name,
+		Arguments: args,
+		Body:      body,
+	}
+}
+
+// Captures the environment, so that the body can refer to the variables that
+// were in scope where the lambda was defined, even when it is applied
+// somewhere else (e.g. in a map call).
 func (l *lambda) Eval(env *ScriptEnvironment) (Script, error) {
-	return l, nil
+	if l.env != nil {
+		return l, nil
+	}
+	return &lambda{
+		Name:      l.Name,
+		Arguments: l.Arguments,
+		Body:      l.Body,
+		env:       env,
+	}, nil
 }
 
 func (l *lambda) Type() ValueType {
@@ -431,7 +574,19 @@ func (l *lambda) Value() (interface{}, error) {
 	return nil, fmt.Errorf("Function application can not be converted to Go value (forgot to eval?)")
 }
 func (s *lambda) Equals(s2 Script) bool {
-	return false
+	if !IsLambdaAtom(s2) {
+		return false
+	}
+	l2 := ExpectLambdaAtom(s2)
+	if s.Name != l2.Name || len(s.Arguments) != len(l2.Arguments) {
+		return false
+	}
+	for i, arg := range s.Arguments {
+		if arg != l2.Arguments[i] {
+			return false
+		}
+	}
+	return s.Body.Equals(l2.Body)
 }
 
 func IsLambdaAtom(v Script) bool {
@@ -445,6 +600,405 @@ func ExpectLambdaAtom(v Script) *lambda {
 	panic("Expecting lambda function, got " + v.Type().Name())
 }
 
+/*
+   Conditionals
+*/
+type conditional struct {
+	Condition Script
+	Then      Script
+	Else      Script
+}
+
+// Only the branch that is selected by the condition gets evaluated.
+func NewConditional(condition, then, els Script) Script {
+	return &conditional{
+		Condition: condition,
+		Then:      then,
+		Else:      els,
+	}
+}
+func IsConditionalAtom(s Script) bool {
+	_, ok := s.(*conditional)
+	return ok
+}
+func ExpectConditionalAtom(s Script) *conditional {
+	if IsConditionalAtom(s) {
+		return s.(*conditional)
+	}
+	panic("Expecting conditional, got " + s.Type().Name())
+}
+func (c *conditional) Eval(env *ScriptEnvironment) (Script, error) {
+	cond, err := c.Condition.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if !IsBoolAtom(cond) {
+		return nil, fmt.Errorf("Expecting bool condition in if expression, but got '%s'", cond.Type().Name())
+	}
+	if ExpectBoolAtom(cond) {
+		return c.Then.Eval(env)
+	}
+	return c.Else.Eval(env)
+}
+func (c *conditional) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Conditional can not be converted to Go value (forgot to eval?)")
+}
+func (c *conditional) Type() ValueType {
+	return commonValueType(c.Then.Type(), c.Else.Type())
+}
+func (c *conditional) Equals(s2 Script) bool {
+	if !IsConditionalAtom(s2) {
+		return false
+	}
+	c2 := ExpectConditionalAtom(s2)
+	return c.Condition.Equals(c2.Condition) && c.Then.Equals(c2.Then) && c.Else.Equals(c2.Else)
+}
+
+/*
+   Try expressions
+*/
+type tryExpression struct {
+	Expression Script
+	Fallback   Script
+}
+
+// The fallback is only evaluated when the expression fails. The error is
+// available in the fallback as $error.
+func NewTry(expression, fallback Script) Script {
+	return &tryExpression{
+		Expression: expression,
+		Fallback:   fallback,
+	}
+}
+func IsTryAtom(s Script) bool {
+	_, ok := s.(*tryExpression)
+	return ok
+}
+func ExpectTryAtom(s Script) *tryExpression {
+	if IsTryAtom(s) {
+		return s.(*tryExpression)
+	}
+	panic("Expecting try expression, got " + s.Type().Name())
+}
+func (t *tryExpression) Eval(env *ScriptEnvironment) (Script, error) {
+	result, err := t.Expression.Eval(env)
+	if err == nil {
+		return result, nil
+	}
+	evalErr := newEvalError(t.Expression, err).(*EvalError)
+	newEnv := env.newChildEnvironment(map[string]Script{
+		tryErrorVariable: evalErr.toDict(),
+	})
+	return t.Fallback.Eval(newEnv)
+}
+func (t *tryExpression) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Try expression can not be converted to Go value (forgot to eval?)")
+}
+func (t *tryExpression) Type() ValueType {
+	return commonValueType(t.Expression.Type(), t.Fallback.Type())
+}
+func (t *tryExpression) Equals(s2 Script) bool {
+	if !IsTryAtom(s2) {
+		return false
+	}
+	t2 := ExpectTryAtom(s2)
+	return t.Expression.Equals(t2.Expression) && t.Fallback.Equals(t2.Fallback)
+}
+
+const tryErrorVariable = "error"
+
+/*
+   Let bindings
+*/
+type letBinding struct {
+	Names  []string
+	Values []Script
+	Body   Script
+}
+
+// The values are bound in order, so a value can refer to the names that were
+// bound before it.
+func NewLet(names []string, values []Script, body Script) Script {
+	return &letBinding{
+		Names:  names,
+		Values: values,
+		Body:   body,
+	}
+}
+func IsLetAtom(s Script) bool {
+	_, ok := s.(*letBinding)
+	return ok
+}
+func ExpectLetAtom(s Script) *letBinding {
+	if IsLetAtom(s) {
+		return s.(*letBinding)
+	}
+	panic("Expecting let expression, got " + s.Type().Name())
+}
+func (l *letBinding) Eval(env *ScriptEnvironment) (Script, error) {
+	for ix, name := range l.Names {
+		if err := checkBindableName(name); err != nil {
+			return nil, err
+		}
+		val, err := l.Values[ix].Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		env = env.newChildEnvironment(map[string]Script{name: val})
+	}
+	return l.Body.Eval(env)
+}
+func (l *letBinding) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Let expression can not be converted to Go value (forgot to eval?)")
+}
+func (l *letBinding) Type() ValueType {
+	return l.Body.Type()
+}
+func (l *letBinding) Equals(s2 Script) bool {
+	if !IsLetAtom(s2) {
+		return false
+	}
+	l2 := ExpectLetAtom(s2)
+	if len(l.Names) != len(l2.Names) {
+		return false
+	}
+	for ix, name := range l.Names {
+		if name != l2.Names[ix] || !l.Values[ix].Equals(l2.Values[ix]) {
+			return false
+		}
+	}
+	return l.Body.Equals(l2.Body)
+}
+
+/*
+   Safe navigation
+*/
+type safeNavigation struct {
+	To        Script
+	Field     string
+	Call      bool
+	Arguments []Script
+}
+
+// Looks up the field (e.g. "$p?.outputs"), or calls the method if call is
+// true (e.g. "$p?.upper()"), but evaluates to null instead of failing when
+// the value is null or when the field doesn't exist.
+func NewSafeNavigation(to Script, field string, call bool, args []Script) Script {
+	return &safeNavigation{
+		To:        to,
+		Field:     field,
+		Call:      call,
+		Arguments: args,
+	}
+}
+func IsSafeNavigationAtom(s Script) bool {
+	_, ok := s.(*safeNavigation)
+	return ok
+}
+func ExpectSafeNavigationAtom(s Script) *safeNavigation {
+	if IsSafeNavigationAtom(s) {
+		return s.(*safeNavigation)
+	}
+	panic("Expecting safe navigation, got " + s.Type().Name())
+}
+func (n *safeNavigation) Eval(env *ScriptEnvironment) (Script, error) {
+	to, err := n.evalTarget(env)
+	if err != nil {
+		return nil, err
+	}
+	if IsNullAtom(to) {
+		return to, nil
+	}
+	if n.Call {
+		args := append([]Script{to}, n.Arguments...)
+		return newStdlibCall(n.Field, args).Eval(env)
+	}
+	if IsDictAtom(to) {
+		result, ok := ExpectDictAtom(to)[n.Field]
+		if !ok {
+			return LiftNull(), nil
+		}
+		return result, nil
+	}
+	return NewApply(to, []Script{LiftString(n.Field)}).Eval(env)
+}
+
+// A global that isn't defined (e.g. the "$p" in "$p?.outputs") evaluates to
+// null, like a missing field further down the chain.
+func (n *safeNavigation) evalTarget(env *ScriptEnvironment) (Script, error) {
+	if key, ok := globalLookupKey(n.To); ok {
+		globals, err := ExpectApplyAtom(n.To).To.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if IsDictAtom(globals) {
+			if _, found := ExpectDictAtom(globals)[key]; !found {
+				return LiftNull(), nil
+			}
+		}
+	}
+	return n.To.Eval(env)
+}
+func (n *safeNavigation) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Safe navigation can not be converted to Go value (forgot to eval?)")
+}
+// The type of the field if the target is a dict, or null if the field or the
+// target is missing. Otherwise the type is only known after evaluation.
+func (n *safeNavigation) Type() ValueType {
+	if IsNullAtom(n.To) {
+		return n.To.Type()
+	}
+	if !n.Call && IsDictAtom(n.To) {
+		if field, ok := ExpectDictAtom(n.To)[n.Field]; ok {
+			return field.Type()
+		}
+		return NewType("null")
+	}
+	return NewType("*")
+}
+func (n *safeNavigation) Equals(s2 Script) bool {
+	if !IsSafeNavigationAtom(s2) {
+		return false
+	}
+	n2 := ExpectSafeNavigationAtom(s2)
+	if n.Field != n2.Field || n.Call != n2.Call || len(n.Arguments) != len(n2.Arguments) {
+		return false
+	}
+	for ix, arg := range n.Arguments {
+		if !arg.Equals(n2.Arguments[ix]) {
+			return false
+		}
+	}
+	return n.To.Equals(n2.To)
+}
+
+/*
+   Null coalescing
+*/
+type coalescingOperator struct {
+	Left  Script
+	Right Script
+}
+
+// Evaluates to the left hand side, unless it is null. The right hand side is
+// only evaluated if the left hand side is null.
+func NewCoalesce(left, right Script) Script {
+	return &coalescingOperator{
+		Left:  left,
+		Right: right,
+	}
+}
+func IsCoalesceAtom(s Script) bool {
+	_, ok := s.(*coalescingOperator)
+	return ok
+}
+func ExpectCoalesceAtom(s Script) *coalescingOperator {
+	if IsCoalesceAtom(s) {
+		return s.(*coalescingOperator)
+	}
+	panic("Expecting coalescing operator, got " + s.Type().Name())
+}
+func (c *coalescingOperator) Eval(env *ScriptEnvironment) (Script, error) {
+	left, err := c.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if !IsNullAtom(left) {
+		return left, nil
+	}
+	return c.Right.Eval(env)
+}
+func (c *coalescingOperator) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Coalescing operator can not be converted to Go value (forgot to eval?)")
+}
+func (c *coalescingOperator) Type() ValueType {
+	return commonValueType(c.Left.Type(), c.Right.Type())
+}
+func (c *coalescingOperator) Equals(s2 Script) bool {
+	if !IsCoalesceAtom(s2) {
+		return false
+	}
+	c2 := ExpectCoalesceAtom(s2)
+	return c.Left.Equals(c2.Left) && c.Right.Equals(c2.Right)
+}
+
+/*
+   Short-circuiting logical operators
+*/
+type logicalOperator struct {
+	Operator string
+	Left     Script
+	Right    Script
+}
+
+// The right hand side is only evaluated if the left hand side is true.
+func NewAnd(left, right Script) Script {
+	return &logicalOperator{
+		Operator: "&&",
+		Left:     left,
+		Right:    right,
+	}
+}
+
+// The right hand side is only evaluated if the left hand side is false.
+func NewOr(left, right Script) Script {
+	return &logicalOperator{
+		Operator: "||",
+		Left:     left,
+		Right:    right,
+	}
+}
+func IsLogicalOperatorAtom(s Script) bool {
+	_, ok := s.(*logicalOperator)
+	return ok
+}
+func ExpectLogicalOperatorAtom(s Script) *logicalOperator {
+	if IsLogicalOperatorAtom(s) {
+		return s.(*logicalOperator)
+	}
+	panic("Expecting logical operator, got " + s.Type().Name())
+}
+func (l *logicalOperator) Eval(env *ScriptEnvironment) (Script, error) {
+	left, err := l.evalOperand(l.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	if l.Operator == "&&" && !left {
+		return LiftBool(false), nil
+	}
+	if l.Operator == "||" && left {
+		return LiftBool(true), nil
+	}
+	right, err := l.evalOperand(l.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return LiftBool(right), nil
+}
+func (l *logicalOperator) evalOperand(operand Script, env *ScriptEnvironment) (bool, error) {
+	evaled, err := operand.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	if !IsBoolAtom(evaled) {
+		return false, fmt.Errorf("Expecting bool arguments for '%s', but got '%s'", l.Operator, evaled.Type().Name())
+	}
+	return ExpectBoolAtom(evaled), nil
+}
+func (l *logicalOperator) Value() (interface{}, error) {
+	return nil, fmt.Errorf("Logical operator can not be converted to Go value (forgot to eval?)")
+}
+func (l *logicalOperator) Type() ValueType {
+	return NewType("bool")
+}
+func (l *logicalOperator) Equals(s2 Script) bool {
+	l2, ok := s2.(*logicalOperator)
+	if !ok {
+		return false
+	}
+	return l.Operator == l2.Operator && l.Left.Equals(l2.Left) && l.Right.Equals(l2.Right)
+}
+
 /*
    Apply
 */
@@ -470,6 +1024,21 @@ func ExpectApplyAtom(s Script) *apply {
 	panic("Expecting function application, got " + s.Type().Name())
 }
 func (f *apply) Eval(env *ScriptEnvironment) (Script, error) {
+	tracer := env.GetTracer()
+	if tracer != nil {
+		tracer.enter(f)
+	}
+	result, err := f.eval(env)
+	if err != nil && !isFunctionLookup(f) {
+		err = newEvalError(f, err)
+	}
+	if tracer != nil {
+		tracer.exit(result, err)
+	}
+	return result, err
+}
+
+func (f *apply) eval(env *ScriptEnvironment) (Script, error) {
 	evaledTo, err := f.To.Eval(env)
 	if err != nil {
 		return nil, err
@@ -483,12 +1052,13 @@ func (f *apply) Eval(env *ScriptEnvironment) (Script, error) {
 		}
 		args = append(args, evaledArg)
 	}
+	env.GetTracer().recordApplication(evaledTo, args)
 	if typ.IsFunc() {
 		return f.evalFuncApply(evaledTo, args, env)
 	} else if typ.IsMap() {
 		return f.evalDictApply(evaledTo, args)
 	} else if typ.IsString() {
-		return f.evalStringApply(evaledTo, args)
+		return f.evalStringApply(env, evaledTo, args)
 	} else if typ.IsLambda() {
 		return f.evalLambdaApply(evaledTo, args, env)
 	}
@@ -508,27 +1078,29 @@ func (f *apply) evalLambdaApply(script Script, args []Script, env *ScriptEnviron
 	if len(lambda.Arguments) != len(args) {
 		return nil, fmt.Errorf("Argument arity mismatch. Expecting %d arguments, got %d.", len(lambda.Arguments), len(args))
 	}
-	if env == nil {
-		env = NewScriptEnvironment()
-	}
-	newEnv := map[string]Script{}
-	for key, val := range *env {
-		newEnv[key] = val
+	for _, name := range append([]string{lambda.Name}, lambda.Arguments...) {
+		if err := checkBindableName(name); err != nil {
+			return nil, err
+		}
 	}
-	globals, found := newEnv["$"]
-	if !found {
-		globals = LiftDict(map[string]Script{})
+	depth, err := env.enterCall()
+	if err != nil {
+		return nil, err
 	}
-	globalsDict := ExpectDictAtom(globals)
-	newGlobals := map[string]Script{}
-	for key, val := range globalsDict {
-		newGlobals[key] = val
+	bindings := map[string]Script{}
+	if lambda.Name != "" {
+		bindings[lambda.Name] = lambda
 	}
 	for ix, variable := range lambda.Arguments {
-		newGlobals[variable] = args[ix]
+		bindings[variable] = args[ix]
 	}
-	newEnv["$"] = LiftDict(newGlobals)
-	return lambda.Body.Eval(NewScriptEnvironmentFromMap(newEnv))
+	scope := env
+	if lambda.env != nil {
+		scope = env.withGlobalsFrom(lambda.env)
+	}
+	newEnv := scope.newChildEnvironment(bindings)
+	(*newEnv)[callDepthKey] = LiftInteger(depth)
+	return lambda.Body.Eval(newEnv)
 }
 
 func (f *apply) evalDictApply(dict Script, args []Script) (Script, error) {
@@ -556,7 +1128,7 @@ func (f *apply) evalDictApply(dict Script, args []Script) (Script, error) {
 	return result, nil
 }
 
-func (f *apply) evalStringApply(str Script, args []Script) (Script, error) {
+func (f *apply) evalStringApply(env *ScriptEnvironment, str Script, args []Script) (Script, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("Expecting one argument in string call, but got '%d'", len(args))
 	}
@@ -567,6 +1139,9 @@ func (f *apply) evalStringApply(str Script, args []Script) (Script, error) {
 	applyTo := ExpectStringAtom(str)
 	fun := ExpectStringAtom(arg)
 	if fun == "file" {
+		if err := env.GetEvalPolicy().CheckFileAccess("file"); err != nil {
+			return nil, err
+		}
 		result, err := builtinFileStringFunc(applyTo)
 		if err != nil {
 			return nil, err
@@ -585,7 +1160,19 @@ func (f *apply) Type() ValueType {
 }
 
 func (s *apply) Equals(s2 Script) bool {
-	return false
+	if !IsApplyAtom(s2) {
+		return false
+	}
+	a2 := ExpectApplyAtom(s2)
+	if !s.To.Equals(a2.To) || len(s.Arguments) != len(a2.Arguments) {
+		return false
+	}
+	for i, arg := range s.Arguments {
+		if !arg.Equals(a2.Arguments[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func builtinFileStringFunc(str string) (string, error) {
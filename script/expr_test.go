@@ -17,6 +17,7 @@ limitations under the License.
 package script
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -38,6 +39,20 @@ func (s *exprSuite) Test_Lift_nil_returns_empty_string(c *C) {
 	c.Assert(ExpectStringAtom(v), Equals, "")
 }
 
+func (s *exprSuite) Test_liftDecodedValues_keeps_nulls(c *C) {
+	v, err := Lift(liftDecodedValues(map[string]interface{}{
+		"a": nil,
+		"b": []interface{}{nil, "x"},
+		"c": map[interface{}]interface{}{"d": nil},
+	}))
+	c.Assert(err, IsNil)
+	dict := ExpectDictAtom(v)
+	c.Assert(IsNullAtom(dict["a"]), Equals, true)
+	c.Assert(IsNullAtom(ExpectListAtom(dict["b"])[0]), Equals, true)
+	c.Assert(ExpectStringAtom(ExpectListAtom(dict["b"])[1]), Equals, "x")
+	c.Assert(IsNullAtom(ExpectDictAtom(dict["c"])["d"]), Equals, true)
+}
+
 func (s *exprSuite) Test_Lift_ScriptString(c *C) {
 	v, err := Lift(LiftString("string"))
 	c.Assert(err, IsNil)
@@ -65,8 +80,45 @@ func (s *exprSuite) Test_Lift_Integer(c *C) {
 func (s *exprSuite) Test_Lift_Float(c *C) {
 	v, err := Lift(12.6)
 	c.Assert(err, IsNil)
+	c.Assert(IsFloatAtom(v), Equals, true)
+	c.Assert(ExpectFloatAtom(v), Equals, 12.6)
+}
+func (s *exprSuite) Test_Lift_Float_without_fraction_is_lifted_to_Integer(c *C) {
+	v, err := Lift(12.0)
+	c.Assert(err, IsNil)
+	c.Assert(IsIntegerAtom(v), Equals, true)
+	c.Assert(ExpectIntegerAtom(v), Equals, 12)
+	v, err = Lift(float64(1 << 40))
+	c.Assert(err, IsNil)
+	c.Assert(IsIntegerAtom(v), Equals, true)
+	c.Assert(ExpectIntegerAtom(v), Equals, 1<<40)
+	v, err = Lift(1e20)
+	c.Assert(err, IsNil)
+	c.Assert(IsFloatAtom(v), Equals, true)
+}
+func (s *exprSuite) Test_Lift_Json_Number(c *C) {
+	v, err := Lift(json.Number("12"))
+	c.Assert(err, IsNil)
 	c.Assert(IsIntegerAtom(v), Equals, true)
 	c.Assert(ExpectIntegerAtom(v), Equals, 12)
+	v, err = Lift(json.Number("12.0"))
+	c.Assert(err, IsNil)
+	c.Assert(IsFloatAtom(v), Equals, true)
+	c.Assert(ExpectFloatAtom(v), Equals, 12.0)
+}
+func (s *exprSuite) Test_FormatFloat(c *C) {
+	cases := map[float64]string{
+		0:         "0.0",
+		2:         "2.0",
+		-0.5:      "-0.5",
+		123456789: "123456789.0",
+		0.25:      "0.25",
+		1e21:      "1.0e+21",
+		1.5e-7:    "1.5e-07",
+	}
+	for f, expected := range cases {
+		c.Assert(FormatFloat(f), Equals, expected)
+	}
 }
 func (s *exprSuite) Test_Lift_List(c *C) {
 	list := []interface{}{"test", 12}
@@ -476,3 +528,23 @@ func (s *exprSuite) Test_Eval(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(result, Equals, "1.0")
 }
+
+func (s *exprSuite) Test_Type_of_expressions_with_two_outcomes(c *C) {
+	str := LiftString("a")
+	dict := LiftDict(map[string]Script{"a": str})
+	cases := map[Script]string{
+		NewConditional(LiftBool(true), str, LiftString("b")): "string",
+		NewConditional(LiftBool(true), str, LiftInteger(1)):  "*",
+		NewTry(LiftInteger(1), LiftInteger(2)):               "integer",
+		NewTry(LiftInteger(1), str):                          "*",
+		NewCoalesce(str, str):                                "string",
+		NewCoalesce(LiftNull(), str):                         "*",
+		NewSafeNavigation(LiftNull(), "a", false, nil):       "null",
+		NewSafeNavigation(dict, "a", false, nil):             "string",
+		NewSafeNavigation(dict, "b", false, nil):             "null",
+		NewSafeNavigation(str, "upper", true, []Script{}):    "*",
+	}
+	for script, expected := range cases {
+		c.Assert(script.Type().Name(), Equals, expected, Commentf("Script '%s'", Print(script)))
+	}
+}
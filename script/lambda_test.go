@@ -0,0 +1,89 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalLambda(c *C, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"x":       LiftInteger(1),
+		"version": LiftString("1.2.3"),
+	})
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return EvalToGoValue(script, env)
+}
+
+func (s *exprSuite) Test_Eval_Lambda_closures_and_recursion(c *C) {
+	cases := map[string]interface{}{
+		`$func(x) { $x + 1 }(2)`: 3,
+		`$let(add = $func(a) { $func(b) { $a + $b } }) { [1, 2].map($add(10)) }`:                                           []interface{}{11, 12},
+		`$let(add = $func(a) { $func(b) { $a + $b } }) { $add(10)(5) }`:                                                    15,
+		`$let(f = $func(y) { $x + $y }) { $let(x = 100) { $f(1) } }`:                                                       2,
+		`$let(x = 10, f = $func() { $x }) { $let(x = 20) { $f() } }`:                                                       10,
+		`$func fact(n) { $if($n <= 1, 1, $n * $fact($n - 1)) }(5)`:                                                         120,
+		`$let(fib = $func fib(n) { $if($n < 2, $n, $fib($n - 1) + $fib($n - 2)) }) { [0, 1, 2, 10].map($fib) }`:            []interface{}{0, 1, 1, 55},
+		`$let(track = $func(v, n) { $__list_slice($v.split("."), 0, $n).join(".").concat(".@") }) { $track($version, 2) }`: "1.2.@",
+	}
+	for expr, expected := range cases {
+		val, err := evalLambda(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, DeepEquals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Eval_Lambda_fails_on_infinite_recursion(c *C) {
+	_, err := evalLambda(c, `$func loop(n) { $loop($n + 1) }(0)`)
+	c.Assert(err, ErrorMatches, ".*Maximum call depth of 512 exceeded in lambda call \\(infinite recursion\\?\\)")
+}
+
+func (s *exprSuite) Test_Eval_Lambda_uses_max_call_depth_from_policy(c *C) {
+	policy := NewEvalPolicy()
+	policy.MaxCallDepth = 5
+	val, err := evalWithPolicy(policy, `$func count(n) { $if($n == 0, 0, 1 + $count($n - 1)) }(4)`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, 4)
+	_, err = evalWithPolicy(policy, `$func count(n) { $if($n == 0, 0, 1 + $count($n - 1)) }(5)`)
+	c.Assert(err, ErrorMatches, ".*Maximum call depth of 5 exceeded in lambda call \\(infinite recursion\\?\\)")
+}
+
+func (s *exprSuite) Test_Lambda_Equals_compares_names(c *C) {
+	body := LiftInteger(1)
+	c.Assert(NewNamedLambda("f", []string{"x"}, body).Equals(NewNamedLambda("f", []string{"x"}, body)), Equals, true)
+	c.Assert(NewNamedLambda("f", []string{"x"}, body).Equals(NewNamedLambda("g", []string{"x"}, body)), Equals, false)
+	c.Assert(NewNamedLambda("f", []string{"x"}, body).Equals(NewLambda([]string{"x"}, body)), Equals, false)
+}
+
+func (s *exprSuite) Test_Eval_Lambda_and_let_cant_bind_reserved_names(c *C) {
+	for _, expr := range []string{`$let(__policy = 1) { 1 }`, `$func(__secret) { 1 }(1)`, `$func __tracer() { 1 }()`} {
+		_, err := ParseScript(expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+	}
+	env := NewScriptEnvironment()
+	cases := map[string]Script{
+		"__policy":       NewLet([]string{"__policy"}, []Script{LiftInteger(1)}, LiftInteger(1)),
+		"__call_depth":   NewApply(NewLambda([]string{"__call_depth"}, LiftInteger(1)), []Script{LiftInteger(1)}),
+		"__root_globals": NewApply(NewNamedLambda("__root_globals", []string{}, LiftInteger(1)), []Script{}),
+		"__upper":        NewLet([]string{"__upper"}, []Script{LiftInteger(1)}, LiftInteger(1)),
+	}
+	for name, script := range cases {
+		_, err := script.Eval(env)
+		c.Assert(err, ErrorMatches, "Can't bind reserved name '"+name+"'", Commentf("Name '%s'", name))
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A ParseError is returned by ParseScript when a script can't be parsed. It
+// points at the position where parsing failed.
+type ParseError struct {
+	// The script that was being parsed.
+	Script string
+
+	// A description of what went wrong.
+	Message string
+
+	// The byte offset into the script where parsing failed.
+	Offset int
+
+	// The line and column (both starting at 1) where parsing failed. The
+	// column is counted in characters.
+	Line   int
+	Column int
+
+	// The tokens that would have been accepted at this position, if known.
+	Expected []string
+
+	// The line containing the error, with a caret underneath the position
+	// where parsing failed.
+	Snippet string
+}
+
+// Creates a ParseError for the script, where rest is the input that was left
+// when the error occurred.
+func NewParseError(script, rest, message string, expected []string) *ParseError {
+	offset := parseErrorOffset(script, rest)
+	before := script[:offset]
+	line := strings.Count(before, "\n") + 1
+	lineStart := strings.LastIndex(before, "\n") + 1
+	lineEnd := strings.Index(script[lineStart:], "\n")
+	if lineEnd == -1 {
+		lineEnd = len(script)
+	} else {
+		lineEnd += lineStart
+	}
+	caret := []rune{}
+	for _, c := range script[lineStart:offset] {
+		if c == '\t' {
+			caret = append(caret, '\t')
+		} else {
+			caret = append(caret, ' ')
+		}
+	}
+	return &ParseError{
+		Script:   script,
+		Message:  message,
+		Offset:   offset,
+		Line:     line,
+		Column:   utf8.RuneCountInString(script[lineStart:offset]) + 1,
+		Expected: expected,
+		Snippet:  script[lineStart:lineEnd] + "\n" + string(caret) + "^",
+	}
+}
+
+// The parser works on the remaining input, which is always a suffix of the
+// script, except that trailing whitespace may have been trimmed. Leading
+// whitespace is skipped so that the offset points at the offending token.
+func parseErrorOffset(script, rest string) int {
+	trimmedRest := strings.TrimSpace(rest)
+	offset := len(script) - len(rest)
+	if trimmedRest != "" {
+		offset = len(strings.TrimRightFunc(script, unicode.IsSpace)) - len(trimmedRest)
+	}
+	if offset < 0 || offset > len(script) {
+		return len(script)
+	}
+	return offset
+}
+
+func (p *ParseError) Error() string {
+	return fmt.Sprintf("Couldn't parse expression '%s' at line %d, column %d: %s", p.Script, p.Line, p.Column, p.Message)
+}
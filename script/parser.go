@@ -18,6 +18,7 @@ package script
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -28,6 +29,11 @@ type parseResult struct {
 	Result Script
 	Rest   string
 	Error  error
+
+	// The remaining input at the point where the innermost error occurred,
+	// and the tokens that would have been accepted there.
+	ErrorRest string
+	Expected  []string
 }
 
 func parseSuccess(script Script, rest string) *parseResult {
@@ -36,12 +42,26 @@ func parseSuccess(script Script, rest string) *parseResult {
 		Rest:   rest,
 	}
 }
-func parseError(err error) *parseResult {
+func parseError(err error, rest string, expected ...string) *parseResult {
+	return &parseResult{
+		Error:     err,
+		ErrorRest: rest,
+		Expected:  expected,
+	}
+}
+
+// Wraps the error in inner, but keeps its position so that errors are
+// reported where they actually occurred.
+func parseErrorWrap(err error, inner *parseResult) *parseResult {
 	return &parseResult{
-		Error: err,
+		Error:     err,
+		ErrorRest: inner.ErrorRest,
+		Expected:  inner.Expected,
 	}
 }
 
+var expressionStartTokens = []string{"$", `"`, "(", "[", "{", "!", "[0-9]", "-"}
+
 func ParseScript(str string) (Script, error) {
 	var result *parseResult
 	if strings.HasPrefix(str, "$$") {
@@ -54,10 +74,10 @@ func ParseScript(str string) (Script, error) {
 		return LiftString(str), nil
 	}
 	if result.Error != nil {
-		return nil, fmt.Errorf("Couldn't parse expression '%s': %s", str, result.Error.Error())
+		return nil, NewParseError(str, result.ErrorRest, result.Error.Error(), result.Expected)
 	}
-	if result.Rest != "" {
-		return nil, fmt.Errorf("Invalid expression, unexpected '%s'", result.Rest)
+	if rest := skipWhitespace(result.Rest); rest != "" {
+		return nil, NewParseError(str, rest, fmt.Sprintf("Invalid expression, unexpected '%s'", rest), []string{"EOF"})
 	}
 	return result.Result, nil
 }
@@ -70,62 +90,363 @@ func ShouldParse(str string) Script {
 	return result
 }
 
-// TODO
-// "this should concat {{ $gcp.test }} yall"
-
+// Parses strings with embedded expressions, e.g.
+// "http://{{ $this.inputs.host }}:{{ $this.inputs.port }}/"
+// into a call to concat. Literal braces can be escaped using "\{{". Braces
+// that are not followed by a '$' are kept as they are, so that templates for
+// other tools (e.g. Helm's "{{ .Values.x }}") can be used in strings.
 func parseExpressionInString(str string) *parseResult {
-	return parseSuccess(LiftString(str), "")
+	parts := []Script{}
+	literal := ""
+	for str != "" {
+		if strings.HasPrefix(str, "\\{{") {
+			literal += "{{"
+			str = str[3:]
+			continue
+		}
+		if !strings.HasPrefix(str, "{{") {
+			literal += str[0:1]
+			str = str[1:]
+			continue
+		}
+		if !strings.HasPrefix(skipWhitespace(str[2:]), "$") {
+			literal += "{{"
+			str = str[2:]
+			continue
+		}
+		if literal != "" {
+			parts = append(parts, LiftString(literal))
+			literal = ""
+		}
+		orig := str
+		result := parseExpression(skipWhitespace(str[2:]))
+		if result.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse expression in '%s': %s", orig, result.Error.Error()), result)
+		}
+		rest := skipWhitespace(result.Rest)
+		if rest == "" {
+			return parseError(fmt.Errorf("Expecting '}}' to close '{{', got EOF in '%s'", orig), rest, "}}")
+		}
+		if !strings.HasPrefix(rest, "}}") {
+			return parseError(fmt.Errorf("Expecting '}}', got '%s'", rest), rest, "}}")
+		}
+		parts = append(parts, result.Result)
+		str = rest[2:]
+	}
+	if literal != "" || len(parts) == 0 {
+		parts = append(parts, LiftString(literal))
+	}
+	if len(parts) == 1 && IsStringAtom(parts[0]) {
+		return parseSuccess(parts[0], "")
+	}
+	return parseSuccess(newStdlibCall("concat", parts), "")
+}
+
+type binaryOperator struct {
+	Symbol string
+
+	// The stdlib function the operator is lowered to, if any. Used by the
+	// printer to turn function calls back into infix expressions.
+	FuncName string
+
+	Lower func(left, right Script) Script
+}
+
+func newBinaryOperator(symbol, funcName string) binaryOperator {
+	return binaryOperator{
+		Symbol:   symbol,
+		FuncName: funcName,
+		Lower: func(left, right Script) Script {
+			return newStdlibCall(funcName, []Script{left, right})
+		},
+	}
+}
+
+// Binary operators grouped by precedence, from lowest to highest. Longer
+// symbols need to come before their prefixes (e.g. "<=" before "<").
+var binaryOperators = [][]binaryOperator{
+	[]binaryOperator{binaryOperator{"??", "", NewCoalesce}},
+	[]binaryOperator{binaryOperator{"||", "", NewOr}},
+	[]binaryOperator{binaryOperator{"&&", "", NewAnd}},
+	[]binaryOperator{
+		newBinaryOperator("==", "equals"),
+		binaryOperator{"!=", "", func(left, right Script) Script {
+			return newStdlibCall("not", []Script{newStdlibCall("equals", []Script{left, right})})
+		}},
+	},
+	[]binaryOperator{
+		newBinaryOperator("<=", "lte"),
+		newBinaryOperator(">=", "gte"),
+		newBinaryOperator("<", "lt"),
+		newBinaryOperator(">", "gt"),
+	},
+	[]binaryOperator{
+		newBinaryOperator("+", "add"),
+		newBinaryOperator("-", "subtract"),
+	},
+	[]binaryOperator{
+		newBinaryOperator("*", "multiply"),
+		newBinaryOperator("/", "divide"),
+		newBinaryOperator("%", "modulo"),
+	},
+}
+
+// The parser shares a single env lookup function, so that parsed scripts can
+// be compared using Equals.
+var envLookupFunction = LiftFunction(builtinEnvLookup)
+
+func newStdlibCall(funcName string, args []Script) Script {
+	apply2 := NewApply(envLookupFunction, []Script{LiftString("$")})
+	apply1 := NewApply(apply2, []Script{LiftString("__" + funcName)})
+	return NewApply(apply1, args)
+}
+
+// Skips whitespace, including newlines, and '#' comments, which run until the
+// end of the line.
+func skipWhitespace(str string) string {
+	for {
+		str = strings.TrimLeftFunc(str, unicode.IsSpace)
+		if !strings.HasPrefix(str, "#") {
+			return str
+		}
+		end := strings.Index(str, "\n")
+		if end == -1 {
+			return ""
+		}
+		str = str[end+1:]
+	}
 }
 
 func parseExpression(str string) *parseResult {
+	return parseBinaryExpression(0, str)
+}
+
+func parseBinaryExpression(precedence int, str string) *parseResult {
+	if precedence >= len(binaryOperators) {
+		return parseUnaryExpression(str)
+	}
+	result := parseBinaryExpression(precedence+1, str)
+	if result.Error != nil {
+		return result
+	}
+	left := result.Result
+	rest := result.Rest
+	for {
+		trimmed := skipWhitespace(rest)
+		var operator *binaryOperator
+		for i, op := range binaryOperators[precedence] {
+			if strings.HasPrefix(trimmed, op.Symbol) {
+				operator = &binaryOperators[precedence][i]
+				break
+			}
+		}
+		if operator == nil {
+			break
+		}
+		right := parseBinaryExpression(precedence+1, skipWhitespace(trimmed[len(operator.Symbol):]))
+		if right.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse right hand side of '%s': %s", operator.Symbol, right.Error.Error()), right)
+		}
+		left = operator.Lower(left, right.Result)
+		rest = right.Rest
+	}
+	return parseSuccess(left, rest)
+}
+
+func parseUnaryExpression(str string) *parseResult {
+	if strings.HasPrefix(str, "!") {
+		result := parseUnaryExpression(skipWhitespace(str[1:]))
+		if result.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse operand of '!': %s", result.Error.Error()), result)
+		}
+		return parseSuccess(newStdlibCall("not", []Script{result.Result}), result.Rest)
+	}
+	return parsePrimaryExpression(str)
+}
+
+func parsePrimaryExpression(str string) *parseResult {
 	var result *parseResult
 	if str == "" {
-		return parseError(fmt.Errorf("Expecting expression starting with '$' or '\"' or '[0-9\\-]', got empty string"))
+		return parseError(fmt.Errorf("Expecting expression starting with '$', '\"', '(', '[', '{', '!' or '[0-9\\-]', got empty string"), str, expressionStartTokens...)
 	} else if strings.HasPrefix(str, "$") {
 		result = parseEnvLookup(str)
 	} else if strings.HasPrefix(str, "\"") {
 		result = parseString(str)
+	} else if strings.HasPrefix(str, "(") {
+		result = parseParens(str)
+	} else if strings.HasPrefix(str, "[") {
+		result = parseListLiteral(str)
+	} else if strings.HasPrefix(str, "{") {
+		result = parseDictLiteral(str)
 	} else if unicode.IsDigit(rune(str[0])) || str[0:1] == "-" {
-		result = parseInteger(str)
+		result = parseNumber(str)
 	}
 	if result == nil {
-		return parseError(fmt.Errorf("Expecting expression starting with '$' or '\"' or '[0-9\\-]', got: '%s'", str))
+		return parseError(fmt.Errorf("Expecting expression starting with '$', '\"', '(', '[', '{', '!' or '[0-9\\-]', got: '%s'", str), str, expressionStartTokens...)
 	}
 	if result.Error != nil {
 		return result
 	}
-	if strings.HasPrefix(result.Rest, ".") {
-		return parseApply(result.Result, result.Rest)
+	rest := skipWhitespace(result.Rest)
+	if isApplyStart(rest) {
+		return parseApply(result.Result, rest)
 	}
-	if strings.HasPrefix(result.Rest, "[") {
-		return parseListIndex(result.Result, result.Rest)
+	if strings.HasPrefix(rest, "[") {
+		return parseListIndex(result.Result, rest)
 	}
 	return result
 }
 
+func parseParens(str string) *parseResult {
+	if !strings.HasPrefix(str, "(") {
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
+	}
+	result := parseExpression(skipWhitespace(str[1:]))
+	if result.Error != nil {
+		return result
+	}
+	rest := skipWhitespace(result.Rest)
+	if !strings.HasPrefix(rest, ")") {
+		return parseError(fmt.Errorf("Expecting ')', got '%s'", rest), rest, ")")
+	}
+	return parseSuccess(result.Result, rest[1:])
+}
+
+func parseListLiteral(str string) *parseResult {
+	if !strings.HasPrefix(str, "[") {
+		return parseError(fmt.Errorf("Expecting '[', got '%s'", str), str, "[")
+	}
+	result := []Script{}
+	orig := str
+	str = skipWhitespace(str[1:])
+
+	for {
+		if str == "" {
+			return parseError(fmt.Errorf("Expecting ']', got EOF in %s", orig), str, "]")
+		}
+		if strings.HasPrefix(str, "]") {
+			break
+		}
+
+		item := parseExpression(str)
+		if item.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse list item: %s", item.Error.Error()), item)
+		}
+		result = append(result, item.Result)
+
+		str = skipWhitespace(item.Rest)
+		if strings.HasPrefix(str, "]") {
+			break
+		}
+		if !strings.HasPrefix(str, ",") {
+			return parseError(fmt.Errorf("Expecting ',' or ']', but got: \"%s\" in \"%s\"", str, orig), str, ",", "]")
+		}
+		str = skipWhitespace(str[1:])
+	}
+	return parseSuccess(LiftList(result), str[1:])
+}
+
+func parseDictLiteral(str string) *parseResult {
+	if !strings.HasPrefix(str, "{") {
+		return parseError(fmt.Errorf("Expecting '{', got '%s'", str), str, "{")
+	}
+	result := map[string]Script{}
+	orig := str
+	str = skipWhitespace(str[1:])
+
+	for {
+		if str == "" {
+			return parseError(fmt.Errorf("Expecting '}', got EOF in %s", orig), str, "}")
+		}
+		if strings.HasPrefix(str, "}") {
+			break
+		}
+
+		key := parseString(str)
+		if key.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse dictionary key: %s", key.Error.Error()), key)
+		}
+		str = skipWhitespace(key.Rest)
+		if !strings.HasPrefix(str, ":") {
+			return parseError(fmt.Errorf("Expecting ':', but got: \"%s\" in \"%s\"", str, orig), str, ":")
+		}
+		value := parseExpression(skipWhitespace(str[1:]))
+		if value.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse dictionary value: %s", value.Error.Error()), value)
+		}
+		result[ExpectStringAtom(key.Result)] = value.Result
+
+		str = skipWhitespace(value.Rest)
+		if strings.HasPrefix(str, "}") {
+			break
+		}
+		if !strings.HasPrefix(str, ",") {
+			return parseError(fmt.Errorf("Expecting ',' or '}', but got: \"%s\" in \"%s\"", str, orig), str, ",", "}")
+		}
+		str = skipWhitespace(str[1:])
+	}
+	return parseSuccess(LiftDict(result), str[1:])
+}
+
+// Parses an integer or a float. Floats need digits on both sides of the
+// decimal point (e.g. "0.5", "-1.25" or "1.5e3").
+func parseNumber(str string) *parseResult {
+	result := parseInteger(str)
+	if result.Error != nil {
+		return result
+	}
+	rest := result.Rest
+	if len(rest) < 2 || rest[0] != '.' || !unicode.IsDigit(rune(rest[1])) {
+		return result
+	}
+	end := skipDigits(rest, 1)
+	if end < len(rest) && (rest[end] == 'e' || rest[end] == 'E') {
+		exponent := end + 1
+		if exponent < len(rest) && (rest[exponent] == '+' || rest[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(rest) && unicode.IsDigit(rune(rest[exponent])) {
+			end = skipDigits(rest, exponent)
+		}
+	}
+	literal := strings.TrimSpace(str[:len(str)-len(rest)]) + rest[:end]
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return parseError(fmt.Errorf("Invalid float '%s': %s", literal, err.Error()), str, "[0-9]")
+	}
+	return parseSuccess(LiftFloat(f), rest[end:])
+}
+
+func skipDigits(str string, i int) int {
+	for i < len(str) && unicode.IsDigit(rune(str[i])) {
+		i++
+	}
+	return i
+}
+
 func parseInteger(str string) *parseResult {
 	if str == "" {
-		return parseError(fmt.Errorf("Expecting digit"))
+		return parseError(fmt.Errorf("Expecting digit"), str, "[0-9]", "-")
 	} else if !unicode.IsDigit(rune(str[0])) && str[0:1] != "-" {
-		return parseError(fmt.Errorf("Expecting digit"))
+		return parseError(fmt.Errorf("Expecting digit"), str, "[0-9]", "-")
 	}
 	integer, rest := parsers.ParseInteger(str)
 	if integer == nil {
-		return parseError(fmt.Errorf("Expecting digit"))
+		return parseError(fmt.Errorf("Expecting digit"), str[1:], "[0-9]")
 	}
 	return parseSuccess(LiftInteger(*integer), rest)
 }
 
 func parseString(str string) *parseResult {
 	if !strings.HasPrefix(str, `"`) {
-		return parseError(fmt.Errorf("Expecting '\"'"))
+		return parseError(fmt.Errorf("Expecting '\"'"), str, `"`)
 	}
 	str = str[1:]
 	result := []byte{}
 	escaping := false
 	for {
 		if str == "" {
-			return parseError(fmt.Errorf("Expecting '\"'"))
+			return parseError(fmt.Errorf("Expecting '\"'"), str, `"`)
 		}
 		if strings.HasPrefix(str, "\"") && !escaping {
 			break
@@ -147,7 +468,7 @@ func parseString(str string) *parseResult {
 			} else if str[0] == '\\' {
 				result = append(result, '\\')
 			} else {
-				return parseError(fmt.Errorf("Unexpected escape character '%v' in '%s'", str[0], str))
+				return parseError(fmt.Errorf("Unexpected escape character '%v' in '%s'", str[0], str), str, "n", `"`, "t", "\\")
 			}
 		} else {
 			result = append(result, str[0])
@@ -160,7 +481,7 @@ func parseString(str string) *parseResult {
 
 func parseEnvLookup(str string) *parseResult {
 	if !strings.HasPrefix(str, "$") {
-		return parseError(fmt.Errorf("Expecting '$'"))
+		return parseError(fmt.Errorf("Expecting '$'"), str, "$")
 	}
 	str = str[1:]
 	result, rest := parsers.ParseIdent(str)
@@ -168,28 +489,55 @@ func parseEnvLookup(str string) *parseResult {
 		if strings.HasPrefix(str, "__") {
 			return parseEnvFuncCall(str)
 		}
-		return parseError(fmt.Errorf("Expecting indentifier, got '%s'", str))
+		return parseError(fmt.Errorf("Expecting indentifier, got '%s'", str), str, "identifier", "__")
 	}
 	if result == "func" {
-		return parseLambdaFunction(rest)
+		return parseLambdaFunction(skipWhitespace(rest))
+	}
+	if result == "if" {
+		return parseConditional(skipWhitespace(rest))
+	}
+	if result == "let" {
+		return parseLet(skipWhitespace(rest))
+	}
+	if result == "try" {
+		return parseTry(skipWhitespace(rest))
+	}
+	if result == "null" {
+		return parseSuccess(LiftNull(), rest)
 	}
-	envLookup := LiftFunction(builtinEnvLookup)
 	key := LiftString(result)
-	apply2 := NewApply(envLookup, []Script{LiftString("$")})
-	apply1 := NewApply(apply2, []Script{key})
+	apply2 := NewApply(envLookupFunction, []Script{LiftString("$")})
+	var apply1 Script = NewApply(apply2, []Script{key})
+	rest = skipWhitespace(rest)
+	for strings.HasPrefix(rest, "(") {
+		parseArgsResult := parseArguments(rest)
+		if parseArgsResult.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Failed to parse function call to $%s: %s", result, parseArgsResult.Error.Error()), parseArgsResult)
+		}
+		apply1 = NewApply(apply1, ExpectListAtom(parseArgsResult.Result))
+		rest = skipWhitespace(parseArgsResult.Rest)
+	}
 	return parseSuccess(apply1, rest)
 }
 
 func parseLambdaFunction(str string) *parseResult {
+	name, rest := parsers.ParseIdent(str)
+	if name != "" {
+		if isKeyword(name) {
+			return parseError(fmt.Errorf("Can't use keyword '%s' as function name", name), str, "identifier")
+		}
+		str = skipWhitespace(rest)
+	}
 	if !strings.HasPrefix(str, "(") {
-		return parseError(fmt.Errorf("Expecting '(', got '%s'", str))
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
 	}
 	orig := str
-	str = str[1:]
+	str = skipWhitespace(str[1:])
 	args := []string{}
 	for {
 		if str == "" {
-			return parseError(fmt.Errorf("Expecting ')', got EOF in %s", orig))
+			return parseError(fmt.Errorf("Expecting ')', got EOF in %s", orig), str, ")")
 		}
 		if strings.HasPrefix(str, ")") {
 			break
@@ -197,60 +545,153 @@ func parseLambdaFunction(str string) *parseResult {
 
 		variable, rest := parsers.ParseIdent(str)
 		if variable == "" {
-			return parseError(fmt.Errorf("Couldn't parse lambda argument: %s", str))
+			return parseError(fmt.Errorf("Couldn't parse lambda argument: %s", str), str, "identifier")
 		}
 		args = append(args, variable)
 
-		str = strings.TrimSpace(rest)
+		str = skipWhitespace(rest)
 		if strings.HasPrefix(str, ")") {
 			break
 		}
 		if !strings.HasPrefix(str, ",") {
-			return parseError(fmt.Errorf("Expecting ',' or ')', but got: \"%s\" in \"%s\"", str, orig))
+			return parseError(fmt.Errorf("Expecting ',' or ')', but got: \"%s\" in \"%s\"", str, orig), str, ",", ")")
 		}
-		str = strings.TrimSpace(str[1:])
+		str = skipWhitespace(str[1:])
 	}
 
-	str = strings.TrimSpace(str[1:])
+	str = skipWhitespace(str[1:])
 	if !strings.HasPrefix(str, "{") {
-		return parseError(fmt.Errorf("Expecting '{', got '%s'", str))
+		return parseError(fmt.Errorf("Expecting '{', got '%s'", str), str, "{")
 	}
 
-	str = strings.TrimSpace(str[1:])
+	str = skipWhitespace(str[1:])
 	bodyResult := parseExpression(str)
 	if bodyResult.Error != nil {
-		return parseError(fmt.Errorf("Couldn't parse lambda body: %s.", bodyResult.Error.Error()))
+		return parseErrorWrap(fmt.Errorf("Couldn't parse lambda body: %s.", bodyResult.Error.Error()), bodyResult)
 	}
 	body := bodyResult.Result
-	str = strings.TrimSpace(bodyResult.Rest)
+	str = skipWhitespace(bodyResult.Rest)
 	if !strings.HasPrefix(str, "}") {
-		return parseError(fmt.Errorf("Expecting '}', got '%s'", str))
+		return parseError(fmt.Errorf("Expecting '}', got '%s'", str), str, "}")
 	}
-	rest := strings.TrimSpace(str[1:])
+	rest = skipWhitespace(str[1:])
 
-	lambda := NewLambda(args, body)
+	lambda := NewNamedLambda(name, args, body)
 	if !strings.HasPrefix(rest, "(") {
 		return parseSuccess(lambda, rest)
 	}
 	parseArgsResult := parseArguments(rest)
 	if parseArgsResult.Error != nil {
-		return parseError(fmt.Errorf("Failed to parse lambda function call: %s", parseArgsResult.Error.Error()))
+		return parseErrorWrap(fmt.Errorf("Failed to parse lambda function call: %s", parseArgsResult.Error.Error()), parseArgsResult)
 	}
 	apply := NewApply(lambda, ExpectListAtom(parseArgsResult.Result))
 	return parseSuccess(apply, parseArgsResult.Rest)
 }
 
+func parseConditional(str string) *parseResult {
+	if !strings.HasPrefix(str, "(") {
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
+	}
+	parseArgsResult := parseArguments(str)
+	if parseArgsResult.Error != nil {
+		return parseErrorWrap(fmt.Errorf("Failed to parse if expression: %s", parseArgsResult.Error.Error()), parseArgsResult)
+	}
+	args := ExpectListAtom(parseArgsResult.Result)
+	if len(args) != 3 {
+		return parseError(fmt.Errorf("Expecting 3 arguments in if expression (condition, then, else), got %d", len(args)), str)
+	}
+	return parseSuccess(NewConditional(args[0], args[1], args[2]), parseArgsResult.Rest)
+}
+
+func parseTry(str string) *parseResult {
+	if !strings.HasPrefix(str, "(") {
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
+	}
+	parseArgsResult := parseArguments(str)
+	if parseArgsResult.Error != nil {
+		return parseErrorWrap(fmt.Errorf("Failed to parse try expression: %s", parseArgsResult.Error.Error()), parseArgsResult)
+	}
+	args := ExpectListAtom(parseArgsResult.Result)
+	if len(args) != 2 {
+		return parseError(fmt.Errorf("Expecting 2 arguments in try expression (expression, fallback), got %d", len(args)), str)
+	}
+	return parseSuccess(NewTry(args[0], args[1]), parseArgsResult.Rest)
+}
+
+// Parses let expressions, e.g. "$let(x = $a.split("/"), y = $x[0]) { $y }"
+func parseLet(str string) *parseResult {
+	if !strings.HasPrefix(str, "(") {
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
+	}
+	orig := str
+	str = skipWhitespace(str[1:])
+	names := []string{}
+	values := []Script{}
+	for {
+		if str == "" {
+			return parseError(fmt.Errorf("Expecting ')', got EOF in %s", orig), str, ")")
+		}
+		if strings.HasPrefix(str, ")") {
+			break
+		}
+
+		name, rest := parsers.ParseIdent(str)
+		if name == "" {
+			return parseError(fmt.Errorf("Couldn't parse let variable: %s", str), str, "identifier")
+		}
+		for _, n := range names {
+			if n == name {
+				return parseError(fmt.Errorf("Variable '%s' is bound more than once in let expression", name), str)
+			}
+		}
+		str = skipWhitespace(rest)
+		if !strings.HasPrefix(str, "=") || strings.HasPrefix(str, "==") {
+			return parseError(fmt.Errorf("Expecting '=', got '%s'", str), str, "=")
+		}
+		valueResult := parseExpression(skipWhitespace(str[1:]))
+		if valueResult.Error != nil {
+			return parseErrorWrap(fmt.Errorf("Couldn't parse value for let variable '%s': %s", name, valueResult.Error.Error()), valueResult)
+		}
+		names = append(names, name)
+		values = append(values, valueResult.Result)
+
+		str = skipWhitespace(valueResult.Rest)
+		if strings.HasPrefix(str, ")") {
+			break
+		}
+		if !strings.HasPrefix(str, ",") {
+			return parseError(fmt.Errorf("Expecting ',' or ')', but got: \"%s\" in \"%s\"", str, orig), str, ",", ")")
+		}
+		str = skipWhitespace(str[1:])
+	}
+
+	str = skipWhitespace(str[1:])
+	if !strings.HasPrefix(str, "{") {
+		return parseError(fmt.Errorf("Expecting '{', got '%s'", str), str, "{")
+	}
+	str = skipWhitespace(str[1:])
+	bodyResult := parseExpression(str)
+	if bodyResult.Error != nil {
+		return parseErrorWrap(fmt.Errorf("Couldn't parse let body: %s.", bodyResult.Error.Error()), bodyResult)
+	}
+	str = skipWhitespace(bodyResult.Rest)
+	if !strings.HasPrefix(str, "}") {
+		return parseError(fmt.Errorf("Expecting '}', got '%s'", str), str, "}")
+	}
+	return parseSuccess(NewLet(names, values, bodyResult.Result), skipWhitespace(str[1:]))
+}
+
 func parseArguments(str string) *parseResult {
 	if !strings.HasPrefix(str, "(") {
-		return parseError(fmt.Errorf("Expecting '(', got '%s'", str))
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", str), str, "(")
 	}
 	result := []Script{}
 	orig := str
-	str = strings.TrimSpace(str[1:])
+	str = skipWhitespace(str[1:])
 
 	for {
 		if str == "" {
-			return parseError(fmt.Errorf("Expecting ')', got EOF in %s", orig))
+			return parseError(fmt.Errorf("Expecting ')', got EOF in %s", orig), str, ")")
 		}
 		if strings.HasPrefix(str, ")") {
 			break
@@ -258,74 +699,81 @@ func parseArguments(str string) *parseResult {
 
 		arg := parseExpression(str)
 		if arg.Error != nil {
-			return parseError(fmt.Errorf("Couldn't parse function argument: %s", arg.Error.Error()))
+			return parseErrorWrap(fmt.Errorf("Couldn't parse function argument: %s", arg.Error.Error()), arg)
 		}
 		result = append(result, arg.Result)
 
-		str = strings.TrimSpace(arg.Rest)
+		str = skipWhitespace(arg.Rest)
 		if strings.HasPrefix(str, ")") {
 			break
 		}
 		if !strings.HasPrefix(str, ",") {
-			return parseError(fmt.Errorf("Expecting ',' or ')', but got: \"%s\" in \"%s\"", str, orig))
+			return parseError(fmt.Errorf("Expecting ',' or ')', but got: \"%s\" in \"%s\"", str, orig), str, ",", ")")
 		}
-		str = strings.TrimSpace(str[1:])
+		str = skipWhitespace(str[1:])
 	}
 	return parseSuccess(LiftList(result), str[1:])
 }
 
 func parseEnvFuncCall(str string) *parseResult {
 	if !strings.HasPrefix(str, "__") {
-		return parseError(fmt.Errorf("Expecting '__', got: '%s'", str))
+		return parseError(fmt.Errorf("Expecting '__', got: '%s'", str), str, "__")
 	}
 	funcName, rest := parsers.ParseIdent(str[2:])
 	if funcName == "" {
-		return parseError(fmt.Errorf("Expecting __indentifier, got '%s'", str))
+		return parseError(fmt.Errorf("Expecting __indentifier, got '%s'", str), str[2:], "identifier")
 	}
+	rest = skipWhitespace(rest)
 	if !strings.HasPrefix(rest, "(") {
-		return parseError(fmt.Errorf("Expecting '(', got '%s'", rest))
+		return parseError(fmt.Errorf("Expecting '(', got '%s'", rest), rest, "(")
 	}
 	parseArgsResult := parseArguments(rest)
 	if parseArgsResult.Error != nil {
-		return parseError(fmt.Errorf("Failed to parse function call to __%s: %s", funcName, parseArgsResult.Error.Error()))
+		return parseErrorWrap(fmt.Errorf("Failed to parse function call to __%s: %s", funcName, parseArgsResult.Error.Error()), parseArgsResult)
 	}
-	envLookup := LiftFunction(builtinEnvLookup)
-	key := LiftString("__" + funcName)
-	apply2 := NewApply(envLookup, []Script{LiftString("$")})
-	apply1 := NewApply(apply2, []Script{key})
-	apply := NewApply(apply1, ExpectListAtom(parseArgsResult.Result))
+	apply := newStdlibCall(funcName, ExpectListAtom(parseArgsResult.Result))
 	return parseSuccess(apply, parseArgsResult.Rest)
 }
 
+// Parses field lookups and method calls using either '.' or the safe
+// navigation operator '?.' (see NewSafeNavigation).
 func parseApply(to Script, str string) *parseResult {
-	if !strings.HasPrefix(str, ".") {
-		return parseError(fmt.Errorf("Expecting '.', got: '%s'", str))
+	safe := strings.HasPrefix(str, "?.")
+	if safe {
+		str = skipWhitespace(str[2:])
+	} else if strings.HasPrefix(str, ".") {
+		str = skipWhitespace(str[1:])
+	} else {
+		return parseError(fmt.Errorf("Expecting '.' or '?.', got: '%s'", str), str, ".", "?.")
 	}
-	str = str[1:]
 	result, rest := parsers.ParseIdent(str)
 	if result == "" {
-		return parseError(fmt.Errorf("Expecting indentifier, got '%s'", str))
+		return parseError(fmt.Errorf("Expecting indentifier, got '%s'", str), str, "identifier")
 	}
+	rest = skipWhitespace(rest)
 	var apply Script
 	if strings.HasPrefix(rest, "(") {
 		parseArgsResult := parseArguments(rest)
 		if parseArgsResult.Error != nil {
-			return parseError(fmt.Errorf("Failed to parse function call to __%s: %s", result, parseArgsResult.Error.Error()))
+			return parseErrorWrap(fmt.Errorf("Failed to parse function call to __%s: %s", result, parseArgsResult.Error.Error()), parseArgsResult)
 		}
-		envLookup := LiftFunction(builtinEnvLookup)
-		funcName := LiftString("__" + result)
-		apply2 := NewApply(envLookup, []Script{LiftString("$")})
-		apply1 := NewApply(apply2, []Script{funcName})
-		args := []Script{to}
-		for _, arg := range ExpectListAtom(parseArgsResult.Result) {
-			args = append(args, arg)
+		if safe {
+			apply = NewSafeNavigation(to, result, true, ExpectListAtom(parseArgsResult.Result))
+		} else {
+			args := []Script{to}
+			for _, arg := range ExpectListAtom(parseArgsResult.Result) {
+				args = append(args, arg)
+			}
+			apply = newStdlibCall(result, args)
 		}
-		apply = NewApply(apply1, args)
 		rest = parseArgsResult.Rest
+	} else if safe {
+		apply = NewSafeNavigation(to, result, false, nil)
 	} else {
 		apply = NewApply(to, []Script{LiftString(result)})
 	}
-	if strings.HasPrefix(rest, ".") {
+	rest = skipWhitespace(rest)
+	if isApplyStart(rest) {
 		return parseApply(apply, rest)
 	}
 	if strings.HasPrefix(rest, "[") {
@@ -334,55 +782,54 @@ func parseApply(to Script, str string) *parseResult {
 	return parseSuccess(apply, rest)
 }
 
+func isApplyStart(str string) bool {
+	return strings.HasPrefix(str, ".") || strings.HasPrefix(str, "?.")
+}
+
 func parseListIndex(lst Script, str string) *parseResult {
 	if !strings.HasPrefix(str, "[") {
-		return parseError(fmt.Errorf("Expecting '[', got: '%s'", str))
+		return parseError(fmt.Errorf("Expecting '[', got: '%s'", str), str, "[")
 	}
 	isBeginSlice := false
-	str = strings.TrimSpace(str[1:])
+	str = skipWhitespace(str[1:])
 	if strings.HasPrefix(str, ":") {
 		isBeginSlice = true
 		str = str[1:]
 	}
 	intResult := parseInteger(str)
 	if intResult.Error != nil {
-		return parseError(fmt.Errorf("Couldn't parse '%s': %s", str, intResult.Error.Error()))
+		return parseErrorWrap(fmt.Errorf("Couldn't parse '%s': %s", str, intResult.Error.Error()), intResult)
 	}
-	rest := strings.TrimSpace(intResult.Rest)
+	rest := skipWhitespace(intResult.Rest)
 	if rest == "" || rest[0:1] != "]" && rest[0:1] != ":" {
-		return parseError(fmt.Errorf("Expecting ']' or ':', got: '%s'", rest))
+		return parseError(fmt.Errorf("Expecting ']' or ':', got: '%s'", rest), rest, "]", ":")
 	}
-	envLookup := LiftFunction(builtinEnvLookup)
-	apply2 := NewApply(envLookup, []Script{LiftString("$")})
 	var apply Script
 
 	if rest[0:1] == ":" {
-		rest = strings.TrimSpace(rest[1:])
-		apply1 := NewApply(apply2, []Script{LiftString("__list_slice")})
+		rest = skipWhitespace(rest[1:])
 		if strings.HasPrefix(rest, "]") {
-			apply = NewApply(apply1, []Script{lst, intResult.Result})
+			apply = newStdlibCall("list_slice", []Script{lst, intResult.Result})
 		} else {
 			endSlice := parseInteger(rest)
 			if endSlice.Error != nil {
-				return parseError(fmt.Errorf("Couldn't parse '%s': %s", str, endSlice.Error.Error()))
+				return parseErrorWrap(fmt.Errorf("Couldn't parse '%s': %s", str, endSlice.Error.Error()), endSlice)
 			}
-			rest = strings.TrimSpace(endSlice.Rest)
+			rest = skipWhitespace(endSlice.Rest)
 			if !strings.HasPrefix(rest, "]") {
-				return parseError(fmt.Errorf("Expecting ']', got: '%s'", rest))
+				return parseError(fmt.Errorf("Expecting ']', got: '%s'", rest), rest, "]")
 			}
-			apply = NewApply(apply1, []Script{lst, intResult.Result, endSlice.Result})
+			apply = newStdlibCall("list_slice", []Script{lst, intResult.Result, endSlice.Result})
 		}
 	} else {
 		if !isBeginSlice {
-			apply1 := NewApply(apply2, []Script{LiftString("__list_index")})
-			apply = NewApply(apply1, []Script{lst, intResult.Result})
+			apply = newStdlibCall("list_index", []Script{lst, intResult.Result})
 		} else {
-			apply1 := NewApply(apply2, []Script{LiftString("__list_slice")})
-			apply = NewApply(apply1, []Script{lst, LiftInteger(0), intResult.Result})
+			apply = newStdlibCall("list_slice", []Script{lst, LiftInteger(0), intResult.Result})
 		}
 	}
-	rest = rest[1:]
-	if strings.HasPrefix(rest, ".") {
+	rest = skipWhitespace(rest[1:])
+	if isApplyStart(rest) {
 		return parseApply(apply, rest)
 	}
 	return parseSuccess(apply, rest)
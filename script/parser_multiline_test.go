@@ -0,0 +1,172 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+)
+
+func (p *parserSuite) Test_Parse_ignores_whitespace_and_comments(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"x": LiftString("abc"),
+	})
+	cases := map[string]interface{}{
+		"$x # comment":   "abc",
+		"$x\n":           "abc",
+		"$x\n.upper()":   "ABC",
+		"$x.\n  upper()": "ABC",
+		"$x . upper ( )": "ABC",
+		"$x\n  # comment\n  .upper()\n  # comment":           "ABC",
+		"$x.concat(\n  \"d\", # first\n  \"e\"\n)":           "abcde",
+		"$__concat(\"# not a comment\")":                     "# not a comment",
+		"$__concat (\"a\")":                                  "a",
+		"$x.length()\n  + 1\n  * 2":                          5,
+		"$__id([\n  1,\n  2,\n])[\n1\n]":                     2,
+		"$__id({\n  \"a\": 1, # one\n}).a":                   1,
+		"$x?.\n  upper()":                                    "ABC",
+		"$if (\n  $x == \"abc\",\n  1,\n  2\n)":              1,
+		"$let (\n  y = 1, # one\n  z = 2\n) {\n  $y + $z\n}": 3,
+		"$func (a) {\n  # comment\n  $a.upper()\n}($x)":      "ABC",
+		"a\n{{ $x # comment\n}}b":                            "a\nabcb",
+		"# not an expression":                                "# not an expression",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+// Mappings as they are written in Escape plans, using YAML literal (|) and
+// folded (>) blocks.
+const multiLinePlan = `
+depends:
+- id: gcp-cluster
+  mapping:
+    cluster_name: |
+      $let(parts = $gcp.outputs.cluster.split("/")) {
+        # projects/<project>/zones/<zone>/clusters/<name>
+        $parts[5]
+      }
+    zone: >
+      $gcp.outputs.cluster
+      .split("/")[3]
+      .upper()
+    url: |
+      $gcp?.outputs?.url   # only set when the cluster has a public endpoint
+        ?? "http://localhost"
+    labels: |
+      $this.inputs.labels.merge({
+        "app": $this.name,   # used by the service selector
+        "tier": "backend",
+      })
+    replicas: >-
+      $if($this.environment == "production",
+          $this.inputs.replicas * 2,
+          1)
+    hosts: |
+      $this.inputs.hosts
+        .filter($func(h) {
+          $h != "localhost"   # never expose localhost
+        })
+        .map($func(h) { $h.concat(":", $this.inputs.port) })
+        .join(",")
+templates:
+- file: motd.tpl
+  mapping:
+    motd: |
+      Welcome to {{ $this.name }} ({{
+        $this.environment.upper()  # e.g. PRODUCTION
+      }})
+`
+
+func (p *parserSuite) Test_Parse_And_Eval_multi_line_plan_mappings(c *C) {
+	plan := struct {
+		Depends []struct {
+			Mapping map[string]string `yaml:"mapping"`
+		} `yaml:"depends"`
+		Templates []struct {
+			Mapping map[string]string `yaml:"mapping"`
+		} `yaml:"templates"`
+	}{}
+	c.Assert(yaml.Unmarshal([]byte(multiLinePlan), &plan), IsNil)
+
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"gcp": LiftDict(map[string]Script{
+			"outputs": LiftDict(map[string]Script{
+				"cluster": LiftString("projects/my-project/zones/europe-west1-b/clusters/main"),
+			}),
+		}),
+		"this": LiftDict(map[string]Script{
+			"name":        LiftString("my-app"),
+			"environment": LiftString("production"),
+			"inputs": LiftDict(map[string]Script{
+				"replicas": LiftInteger(3),
+				"port":     LiftInteger(8080),
+				"hosts":    ShouldLift([]interface{}{"localhost", "a.example.com", "b.example.com"}),
+				"labels":   LiftDict(map[string]Script{"team": LiftString("core")}),
+			}),
+		}),
+	})
+	expected := map[string]interface{}{
+		"cluster_name": "main",
+		"zone":         "EUROPE-WEST1-B",
+		"url":          "http://localhost",
+		"labels":       map[string]interface{}{"app": "my-app", "tier": "backend", "team": "core"},
+		"replicas":     6,
+		"hosts":        "a.example.com:8080,b.example.com:8080",
+		"motd":         "Welcome to my-app (PRODUCTION)\n",
+	}
+	mapping := plan.Depends[0].Mapping
+	for key, val := range plan.Templates[0].Mapping {
+		mapping[key] = val
+	}
+	c.Assert(mapping, HasLen, len(expected))
+	for key, str := range mapping {
+		script, err := ParseScript(str)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s': '%s'", key, str))
+		result, err := script.Eval(env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s': '%s'", key, str))
+		value, err := result.Value()
+		c.Assert(err, IsNil)
+		if IsDictAtom(result) {
+			value = ExpectDictAtom(result)
+			goValue := map[string]interface{}{}
+			for k, v := range value.(map[string]Script) {
+				goValue[k], err = v.Value()
+				c.Assert(err, IsNil)
+			}
+			value = goValue
+		}
+		c.Assert(value, DeepEquals, expected[key], Commentf("Error in '%s': '%s'", key, str))
+	}
+}
+
+func (p *parserSuite) Test_ParseScript_reports_positions_in_multi_line_scripts(c *C) {
+	str := "$this.inputs.hosts # comment\n  .map($func(h) {\n    $h.concat(\":\" 80)\n  })"
+	_, err := ParseScript(str)
+	c.Assert(err, Not(IsNil))
+	parseErr, ok := err.(*ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(parseErr.Line, Equals, 3)
+	c.Assert(parseErr.Column, Equals, 19)
+	c.Assert(parseErr.Snippet, Equals, "    $h.concat(\":\" 80)\n                  ^")
+}
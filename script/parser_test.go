@@ -359,7 +359,7 @@ func (p *parserSuite) Test_ParseExpression_fail_table(c *C) {
 	cases := []string{
 		"",
 		"-",
-		"$test()",
+		"$test(",
 		`$test.test(`,
 		`$test.test(12`,
 		`$test.test(12, `,
@@ -373,3 +373,543 @@ func (p *parserSuite) Test_ParseExpression_fail_table(c *C) {
 		c.Assert(result.Error != nil || result.Rest != "", Equals, true, Commentf("Shouldn't be able to parse '%s' (error: %s, rest: %s)", testCase, result.Error, result.Rest))
 	}
 }
+
+func (p *parserSuite) Test_Parse_And_Eval_infix_operators(c *C) {
+	globalsDict := map[string]Script{
+		"x":     LiftInteger(10),
+		"y":     LiftInteger(3),
+		"str":   LiftString("test"),
+		"true":  LiftBool(true),
+		"false": LiftBool(false),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := map[string]interface{}{
+		`$x + 1`:                         11,
+		`$x - 1`:                         9,
+		`$x * 2`:                         20,
+		`$x / 3`:                         3,
+		`$x % 3`:                         1,
+		`$x+$y`:                          13,
+		`$x + $y * 2`:                    16,
+		`$x * $y + 2`:                    32,
+		`$y * ($x + $y)`:                 39,
+		`$x - $y - 2`:                    5,
+		`$x / $y / 2`:                    1,
+		`$x == 10`:                       true,
+		`$x != 10`:                       false,
+		`$str == "test"`:                 true,
+		`$str != "other"`:                true,
+		`$x < $y`:                        false,
+		`$x <= 10`:                       true,
+		`$x > $y`:                        true,
+		`$x >= 11`:                       false,
+		`$true && $false`:                false,
+		`$true || $false`:                true,
+		`$true && !$false`:               true,
+		`$true && !!$true`:               true,
+		`$true && !($x == 10)`:           false,
+		`$x > 5 && $y < 5`:               true,
+		`$x > 5 && $y > 5 || $x == 10`:   true,
+		`$x > 5 && ($y > 5 || $x == 0)`:  false,
+		`$x + 1 == 11 && $str == "test"`: true,
+		`$str.length() * 2`:              8,
+		`$__id($x + 1).concat("!")`:      "11!",
+		`$__concat("a", (1 + 2) * 2)`:    "a6",
+		`$func(a) { $a * 2 }($x + 1)`:    22,
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, Equals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_floats(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"x":    LiftInteger(10),
+		"half": LiftFloat(0.5),
+	})
+	cases := map[string]interface{}{
+		`$__id(0.5)`:            0.5,
+		`$__id(-1.25)`:          -1.25,
+		`$__id(1.5e3)`:          1500.0,
+		`$__id(2.0)`:            2.0,
+		`$__id([1, 2.5])`:       []interface{}{1, 2.5},
+		`$half * 3`:             1.5,
+		`$x * $half`:            5.0,
+		`$x + 0.25`:             10.25,
+		`$x - 0.5`:              9.5,
+		`$x / 4`:                2,
+		`$x / 4.0`:              2.5,
+		`$x % 3.5`:              3.0,
+		`$half < 1`:             true,
+		`$half >= 0.5`:          true,
+		`$x > 9.99`:             true,
+		`$x == 10.0`:            true,
+		`$half == 0.5`:          true,
+		`$half != 1`:            true,
+		`$x.concat(" ", $half)`: "10 0.5",
+		`$__concat($x * 1.0)`:   "10.0",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+	for _, testCase := range []string{`$x / 0.0`, `$half % 0`} {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil)
+		_, err = EvalToGoValue(script, env)
+		c.Assert(err, ErrorMatches, "Division by zero", Commentf("Should have failed '%s'", testCase))
+	}
+	_, err := ParseAndEvalToGoValue(`$half + "a"`, env)
+	c.Assert(err, ErrorMatches, ".*Expecting integer or float arguments in add call, but got 'float' and 'string'")
+}
+
+func (p *parserSuite) Test_Parse_infix_operators_fail_table(c *C) {
+	cases := []string{
+		`$x +`,
+		`$x + `,
+		`$x == `,
+		`$x && && $y`,
+		`$x * ($x + 1`,
+		`$x && !`,
+		`$x * (`,
+	}
+	for _, testCase := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Eval_infix_division_by_zero(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{"x": LiftInteger(10)})
+	for _, testCase := range []string{`$x / 0`, `$x % 0`} {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil)
+		_, err = EvalToGoValue(script, env)
+		c.Assert(err, Not(IsNil), Commentf("Should have failed '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_expression_in_string(c *C) {
+	inputsDict := LiftDict(map[string]Script{
+		"host": LiftString("localhost"),
+		"port": LiftInteger(8080),
+	})
+	globalsDict := map[string]Script{
+		"this": LiftDict(map[string]Script{
+			"inputs": inputsDict,
+		}),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := map[string]string{
+		`{{ $this.inputs.host }}`:                                 "localhost",
+		`{{$this.inputs.port}}`:                                   "8080",
+		`http://{{ $this.inputs.host }}:{{ $this.inputs.port }}/`: "http://localhost:8080/",
+		`port {{ $this.inputs.port + 1 }}`:                        "port 8081",
+		`{{ $this.inputs.host.upper() }}{{ $this.inputs.host }}`:  "LOCALHOSTlocalhost",
+		`{{ $__concat("}}") }}`:                                   "}}",
+		`escaped \{{ $this.inputs.host }}`:                        "escaped {{ $this.inputs.host }}",
+		`\{{ literal }} and {{ $this.inputs.host }}`:              "{{ literal }} and localhost",
+		`backslash \ {{ $this.inputs.host }}`:                     `backslash \ localhost`,
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, Equals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_ParseScript_expression_in_string_without_expressions_is_string(c *C) {
+	result, err := ParseScript(`only \{{ escaped }} braces`)
+	c.Assert(err, IsNil)
+	c.Assert(IsStringAtom(result), Equals, true)
+	c.Assert(ExpectStringAtom(result), Equals, "only {{ escaped }} braces")
+}
+
+func (p *parserSuite) Test_ParseScript_expression_in_string_keeps_other_templates(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"host": LiftString("localhost"),
+	})
+	cases := map[string]string{
+		`{{ .Values.x }}`:                        "{{ .Values.x }}",
+		`image: {{ .Values.image }}:{{ $host }}`: "image: {{ .Values.image }}:localhost",
+		`{{- if .Values.enabled }}yes{{- end }}`: "{{- if .Values.enabled }}yes{{- end }}",
+		`{{`:                                     "{{",
+		`{{ }}`:                                  "{{ }}",
+		`{{ "not an expression" }}`:              `{{ "not an expression" }}`,
+		`{{{ $host }}}`:                          "{{{ $host }}}",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, Equals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_ParseScript_expression_in_string_fail_table(c *C) {
+	cases := []string{
+		`test {{ $this.inputs.host `,
+		`test {{ $this.inputs.host } }`,
+		`test {{ $this.inputs.host $this }}`,
+		`test {{ $this.inputs.host }} and {{ $this.inputs.port`,
+	}
+	for _, testCase := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_list_and_dict_literals(c *C) {
+	globalsDict := map[string]Script{
+		"x":   LiftInteger(10),
+		"str": LiftString("test"),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := map[string]interface{}{
+		`$__id([])`:                                 []interface{}{},
+		`$__id([1, "a", $x])`:                       []interface{}{1, "a", 10},
+		`$__id([ $x + 1 , [$str] ])`:                []interface{}{11, []interface{}{"test"}},
+		`$__id([$str, "b"]).join(",")`:              "test,b",
+		`$__id([$str, "b"])[1]`:                     "b",
+		`$__id({})`:                                 map[string]interface{}{},
+		`$__id({"key": $str, "n": $x * 2})`:         map[string]interface{}{"key": "test", "n": 20},
+		`$__id({"nested": {"list": [$x]}})`:         map[string]interface{}{"nested": map[string]interface{}{"list": []interface{}{10}}},
+		`$__id({"key": $str}).key`:                  "test",
+		`$__id({"key": [1, 2, $x]}).key[2]`:         10,
+		`$__id({ "a" : 1 , "b" : 2 }).b`:            2,
+		`{{ $__id(["a", "b"]).join("") }}`:          "ab",
+		`{{ $__id({"host": $str}).host }}:{{ $x }}`: "test:10",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := script.Eval(env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result.Equals(ShouldLift(expected)), Equals, true, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_list_and_dict_literals_fail_table(c *C) {
+	cases := []string{
+		`$__id([1, 2)`,
+		`$__id([1 2])`,
+		`$__id({"key" $x})`,
+		`$__id({key: $x})`,
+		`$__id({"key": })`,
+		`$__id({"key": 1, "b": 2)`,
+		`$__id({"key": 1`,
+	}
+	for _, testCase := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_conditionals(c *C) {
+	globalsDict := map[string]Script{
+		"x":     LiftInteger(10),
+		"url":   LiftString(""),
+		"true":  LiftBool(true),
+		"false": LiftBool(false),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := map[string]interface{}{
+		`$if($true, 1, 2)`:            1,
+		`$if($false, 1, 2)`:           2,
+		`$if($x > 5, "big", "small")`: "big",
+		`$if($x > 5 && $x < 8, "between", $if($x >= 8, "big", "?"))`: "big",
+		`$if($url == "", "file://local", $url)`:                      "file://local",
+		`$if($false, $__read_file("/does/not/exist"), "lazy")`:       "lazy",
+		`$if($true, "lazy", $__read_file("/does/not/exist"))`:        "lazy",
+		`$if($true, "abc", "def").upper()`:                           "ABC",
+		`$if($true, [1, 2], [])[1]`:                                  2,
+		`$false && $__read_file("/does/not/exist")`:                  false,
+		`$true || $__read_file("/does/not/exist")`:                   true,
+		`$true && $x == 10`:                                          true,
+		`$false || $x == 10`:                                         true,
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_conditionals_failing_cases(c *C) {
+	globalsDict := map[string]Script{
+		"x":    LiftInteger(10),
+		"true": LiftBool(true),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := []string{
+		`$if($x, 1, 2)`,
+		`$if($true, $__read_file("/does/not/exist"), 2)`,
+		`$true && $x`,
+		`$x || $true`,
+	}
+	for _, testCase := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		_, err = EvalToGoValue(script, env)
+		c.Assert(err, Not(IsNil), Commentf("Should have failed '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_conditionals_fail_table(c *C) {
+	cases := []string{
+		`$if`,
+		`$if()`,
+		`$if($x, 1)`,
+		`$if($x, 1, 2, 3)`,
+		`$if($x, 1, 2`,
+	}
+	for _, testCase := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_try_fail_table(c *C) {
+	cases := map[string]string{
+		`$try`:           "Expecting '\\(', got ''",
+		`$try()`:         "Expecting 2 arguments in try expression \\(expression, fallback\\), got 0",
+		`$try($x)`:       "Expecting 2 arguments in try expression \\(expression, fallback\\), got 1",
+		`$try($x, 1, 2)`: "Expecting 2 arguments in try expression \\(expression, fallback\\), got 3",
+		`$try($x, 1`:     "Failed to parse try expression: .*",
+	}
+	for testCase, expected := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+		c.Assert(err.(*ParseError).Message, Matches, expected, Commentf("Wrong error for '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_let(c *C) {
+	globalsDict := map[string]Script{
+		"x":       LiftInteger(10),
+		"cluster": LiftString("projects/my-project/zones/europe-west1-b/clusters/main"),
+	}
+	env := NewScriptEnvironmentWithGlobals(globalsDict)
+
+	cases := map[string]interface{}{
+		`$let(y = 1) { $y }`:                           1,
+		`$let(y = $x + 1) { $y * 2 }`:                  22,
+		`$let(y = $x, z = $y + 1) { $z }`:              11,
+		`$let(x = "shadowed") { $x }`:                  "shadowed",
+		`$let(x = $x + 1) { $x }`:                      11,
+		`$let() { $x }`:                                10,
+		`$let(y = 1) { $let(z = 2) { $x + $y + $z } }`: 13,
+		`$let(parts = $cluster.split("/")) { $parts[1].concat("/", $parts[5]) }`: "my-project/main",
+		`$let(f = $func(a) { $a + $x }) { [1, 2].map($f) }`:                      []interface{}{11, 12},
+		`$let(y = $x == 10) { $if($y, "yes", "no") }`:                            "yes",
+		`$let( y=1 ,z=2 ){$y+$z}`:                                                3,
+		`$let(y = "abc") { $y }.upper()`:                                         "ABC",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+	_, err := EvalToGoValue(ShouldParse(`$let(y = 1) { $y }`), env)
+	c.Assert(err, IsNil)
+	_, err = EvalToGoValue(ShouldParse(`$y`), env)
+	c.Assert(err, Not(IsNil), Commentf("Let bindings should not leak into the environment"))
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_let_failing_cases(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{"x": LiftInteger(10)})
+	cases := map[string]string{
+		`$let(y = $__read_file("/does/not/exist")) { 1 }`: ".*no such file or directory",
+		`$let(y = $z, z = 1) { $y }`:                      "Field 'z' was not found .*",
+		`$let(y = 1) { $y.upper() }`:                      "Expecting string argument in call to strings.ToUpper, but got integer",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		_, err = EvalToGoValue(script, env)
+		c.Assert(err, Not(IsNil), Commentf("Should have failed '%s'", testCase))
+		c.Assert(err, ErrorMatches, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_let_fail_table(c *C) {
+	cases := map[string]string{
+		`$let`:                      ".*Expecting '\\(', got ''",
+		`$let(y = 1)`:               ".*Expecting '{', got ''",
+		`$let(y 1) { $y }`:          ".*Expecting '=', got '1\\) { \\$y }'",
+		`$let(y == 1) { $y }`:       ".*Expecting '=', got '== 1\\) { \\$y }'",
+		`$let(1 = 1) { 1 }`:         ".*Couldn't parse let variable: 1 = 1\\) { 1 }",
+		`$let(y = ) { $y }`:         ".*Couldn't parse value for let variable 'y'.*",
+		`$let(y = 1 { $y }`:         ".*Expecting ',' or '\\)'.*",
+		`$let(y = 1) { $y `:         ".*Expecting '}', got ''",
+		`$let(y = 1, y = 2) { $y }`: ".*Variable 'y' is bound more than once in let expression",
+	}
+	for testCase, expected := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+		c.Assert(err, ErrorMatches, "(?s)"+expected, Commentf("Parsing '%s'", testCase))
+	}
+}
+
+func safeNavigationEnv() *ScriptEnvironment {
+	return NewScriptEnvironmentWithGlobals(map[string]Script{
+		"p": LiftDict(map[string]Script{
+			"outputs": LiftDict(map[string]Script{"url": LiftString("http://example.com")}),
+		}),
+		"q": LiftDict(map[string]Script{
+			"outputs": LiftDict(map[string]Script{}),
+		}),
+		"n": LiftNull(),
+		"s": LiftString("abc"),
+	})
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_safe_navigation(c *C) {
+	env := safeNavigationEnv()
+	cases := map[string]interface{}{
+		`$p?.outputs?.url`:                          "http://example.com",
+		`$p?.outputs.url`:                           "http://example.com",
+		`$q?.outputs?.url`:                          nil,
+		`$n?.outputs?.url`:                          nil,
+		`$q?.outputs?.url?.upper()`:                 nil,
+		`$q.outputs?.url ?? "default"`:              "default",
+		`$p.outputs?.url ?? "default"`:              "http://example.com",
+		`$p?.outputs?.url ?? $__read_file("/none")`: "http://example.com",
+		`$n ?? $n ?? 3`:                             3,
+		`$n ?? 1 + 2`:                               3,
+		`$q.outputs?.url.default("fallback")`:       "fallback",
+		`$__default($q.outputs?.url, 1)`:            1,
+		`$__default("set", 1)`:                      "set",
+		`$s?.upper()`:                               "ABC",
+		`$s?.concat("d", 1)`:                        "abcd1",
+		`$n?.upper()`:                               nil,
+		`$null`:                                     nil,
+		`$null == $null`:                            true,
+		`$q.outputs?.url == $null`:                  true,
+		`$p.outputs?.url == $null`:                  false,
+		`$__id([$n ?? 1, 2])`:                       []interface{}{1, 2},
+		`$__to_json({"a": $null})`:                  `{"a":null}`,
+		`{{ $q.outputs?.url ?? "localhost" }}:80`:   "localhost:80",
+		`$missing?.outputs?.url`:                    nil,
+		`$missing?.upper()`:                         nil,
+		`$missing?.outputs?.url ?? "default"`:       "default",
+		`$__from_json("null")`:                      nil,
+		`$__from_json("{\"a\": null}").a == $null`:  true,
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		result, err := EvalToGoValue(script, env)
+		c.Assert(err, IsNil, Commentf("Couldn't evaluate '%s'", testCase))
+		c.Assert(result, DeepEquals, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_And_Eval_safe_navigation_failing_cases(c *C) {
+	env := safeNavigationEnv()
+	cases := map[string]string{
+		`$n.outputs`:                  "Expecting function, map or string for apply, but got 'null'",
+		`$q.outputs.url`:              "Field 'url' was not found \\(target collection was empty\\)",
+		`$q.outputs?.url.upper()`:     "Expecting string argument in call to strings.ToUpper, but got null",
+		`$s?.upper(1)`:                "Expecting 1 argument\\(s\\) in call to 'strings.ToUpper', got 2",
+		`$__concat($null)`:            ".*null.*",
+		`$__default(1)`:               "Expecting 2 argument\\(s\\) in call to 'default', got 1",
+		`$n ?? $__read_file("/none")`: ".*no such file or directory",
+		`$missing.outputs`:            "Field 'missing' was not found .*",
+	}
+	for testCase, expected := range cases {
+		script, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+
+		_, err = EvalToGoValue(script, env)
+		c.Assert(err, Not(IsNil), Commentf("Should have failed '%s'", testCase))
+		c.Assert(err, ErrorMatches, expected, Commentf("Error in '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Parse_safe_navigation_fail_table(c *C) {
+	cases := []string{
+		`$p?.`,
+		`$p?.(`,
+		`$p?.outputs?.url(`,
+		`$p ??`,
+		`$p ?? `,
+	}
+	for _, testCase := range cases {
+		_, err := ParseScript(testCase)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_ParseScript_returns_positioned_errors(c *C) {
+	cases := []struct {
+		Script   string
+		Offset   int
+		Line     int
+		Column   int
+		Expected []string
+		Snippet  string
+	}{
+		{`$__concat("a", 1`, 16, 1, 17, []string{",", ")"}, "$__concat(\"a\", 1\n                ^"},
+		{`$__concat("a" 1)`, 14, 1, 15, []string{",", ")"}, "$__concat(\"a\" 1)\n              ^"},
+		{`$this.inputs.hosts[x]`, 19, 1, 20, []string{"[0-9]", "-"}, "$this.inputs.hosts[x]\n                   ^"},
+		{`$func(x) { $x.upper() `, 22, 1, 23, []string{"}"}, "$func(x) { $x.upper() \n                      ^"},
+		{`$x )`, 3, 1, 4, []string{"EOF"}, "$x )\n   ^"},
+		{`$__id({"a" 1})`, 11, 1, 12, []string{":"}, "$__id({\"a\" 1})\n           ^"},
+		{"$__concat(\n\t\"a\",\n\t$x.\n\t$y)", 23, 4, 2, []string{"identifier"}, "\t$y)\n\t^"},
+		{"prefix {{ $x.upper( }} suffix", 20, 1, 21, expressionStartTokens, "prefix {{ $x.upper( }} suffix\n                    ^"},
+	}
+	for _, test := range cases {
+		_, err := ParseScript(test.Script)
+		c.Assert(err, Not(IsNil), Commentf("Shouldn't be able to parse '%s'", test.Script))
+		parseErr, ok := err.(*ParseError)
+		c.Assert(ok, Equals, true, Commentf("Expecting ParseError for '%s'", test.Script))
+		c.Assert(parseErr.Script, Equals, test.Script)
+		c.Assert(parseErr.Offset, Equals, test.Offset, Commentf("Script '%s'", test.Script))
+		c.Assert(parseErr.Line, Equals, test.Line, Commentf("Script '%s'", test.Script))
+		c.Assert(parseErr.Column, Equals, test.Column, Commentf("Script '%s'", test.Script))
+		c.Assert(parseErr.Expected, DeepEquals, test.Expected, Commentf("Script '%s'", test.Script))
+		c.Assert(parseErr.Snippet, Equals, test.Snippet, Commentf("Script '%s'", test.Script))
+	}
+}
+
+func (p *parserSuite) Test_ParseError_Error(c *C) {
+	_, err := ParseScript(`$__concat("a" 1)`)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, `Couldn't parse expression '$__concat("a" 1)' at line 1, column 15: Failed to parse function call to __concat: Expecting ',' or ')', but got: "1)" in "("a" 1)"`)
+}
+
+func (p *parserSuite) Test_ParseError_column_counts_characters(c *C) {
+	_, err := ParseScript(`$__concat("héllo" 1)`)
+	c.Assert(err, Not(IsNil))
+	parseErr := err.(*ParseError)
+	c.Assert(parseErr.Offset, Equals, 19)
+	c.Assert(parseErr.Column, Equals, 19)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// An EvalPolicy restricts what the builtin functions are allowed to do, so
+// that untrusted scripts (e.g. in third party release metadata) can be
+// evaluated safely. Without a policy scripts have full access to the file
+// system and the clock.
+type EvalPolicy struct {
+	// Disables read_file, path_exists, file_exists, dir_exists and the
+	// .file method, which writes a string to a temporary file.
+	DisableFileAccess bool
+
+	// If set, relative paths are resolved against this directory and paths
+	// outside of it (including through symlinks) are rejected.
+	BaseDir string
+
+	// Disables timestamp and now.
+	DisableClock bool
+
+	// Returns the current time. Can be set to a fixed time to make the
+	// evaluation deterministic. Defaults to time.Now.
+	Clock func() time.Time
+
+	// Disables uuid_v4, and random_string and password without a seed.
+	DisableRandomness bool
+
+	// The source of random bytes. Can be set to a deterministic reader to
+	// make the evaluation reproducible. Defaults to crypto/rand.Reader.
+	Random io.Reader
+
+	// The maximum number of nested lambda calls, which stops runaway
+	// recursion. Defaults to DefaultMaxCallDepth.
+	MaxCallDepth int
+}
+
+const DefaultMaxCallDepth = 512
+
+const evalPolicyKey = "__policy"
+
+func NewEvalPolicy() *EvalPolicy {
+	return &EvalPolicy{
+		Clock: time.Now,
+	}
+}
+
+// Returns a policy that disables file access and randomness and uses a fixed
+// clock, which makes the result of an evaluation depend on the script and its
+// inputs only. Seeded random values are still allowed, because they are
+// derived from the environment's secret.
+func NewSandboxEvalPolicy() *EvalPolicy {
+	return &EvalPolicy{
+		DisableFileAccess: true,
+		DisableRandomness: true,
+		Clock:             FixedClock(time.Unix(0, 0)),
+	}
+}
+
+// Returns an error if the policy doesn't allow funcName to access files.
+func (p *EvalPolicy) CheckFileAccess(funcName string) error {
+	if p.DisableFileAccess {
+		return fmt.Errorf("File access in '%s' is not allowed by the evaluation policy", funcName)
+	}
+	return nil
+}
+
+// Returns the resolved path, or an error if the policy doesn't allow
+// funcName to access it.
+func (p *EvalPolicy) ResolvePath(funcName, path string) (string, error) {
+	if err := p.CheckFileAccess(funcName); err != nil {
+		return "", err
+	}
+	if p.BaseDir == "" {
+		return path, nil
+	}
+	baseDir, err := filepath.Abs(p.BaseDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	path = filepath.Clean(path)
+	if !isInDirectory(baseDir, path) {
+		return "", fmt.Errorf("Path '%s' in '%s' is outside of the base directory", path, funcName)
+	}
+	if resolvedBase, err := filepath.EvalSymlinks(baseDir); err == nil {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil && !isInDirectory(resolvedBase, resolved) {
+			return "", fmt.Errorf("Path '%s' in '%s' is outside of the base directory", path, funcName)
+		}
+	}
+	return path, nil
+}
+
+// Returns a clock that always returns t. Can be used as the policy's Clock
+// to make scripts that use the current time reproducible.
+func FixedClock(t time.Time) func() time.Time {
+	return func() time.Time {
+		return t
+	}
+}
+
+func (p *EvalPolicy) GetMaxCallDepth() int {
+	if p.MaxCallDepth <= 0 {
+		return DefaultMaxCallDepth
+	}
+	return p.MaxCallDepth
+}
+
+func (p *EvalPolicy) Now(funcName string) (time.Time, error) {
+	if p.DisableClock {
+		return time.Time{}, fmt.Errorf("Using the clock in '%s' is not allowed by the evaluation policy", funcName)
+	}
+	if p.Clock == nil {
+		return time.Now(), nil
+	}
+	return p.Clock(), nil
+}
+
+func (p *EvalPolicy) RandomSource(funcName string) (io.Reader, error) {
+	if p.DisableRandomness {
+		return nil, fmt.Errorf("Generating random values in '%s' is not allowed by the evaluation policy", funcName)
+	}
+	if p.Random == nil {
+		return rand.Reader, nil
+	}
+	return p.Random, nil
+}
+
+func isInDirectory(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// The policy is stored in the ScriptEnvironment, which means it needs to be a
+// Script, but it can't be referenced from scripts.
+func (p *EvalPolicy) Eval(env *ScriptEnvironment) (Script, error) {
+	return p, nil
+}
+func (p *EvalPolicy) Value() (interface{}, error) {
+	return p, nil
+}
+func (p *EvalPolicy) Type() ValueType {
+	return NewType("policy")
+}
+func (p *EvalPolicy) Equals(s2 Script) bool {
+	p2, ok := s2.(*EvalPolicy)
+	return ok && p == p2
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func evalWithPolicy(policy *EvalPolicy, expr string) (interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(nil)
+	env.SetEvalPolicy(policy)
+	return ParseAndEvalToGoValue(expr, env)
+}
+
+func makePolicyTestDir(c *C) (string, string) {
+	dir := c.MkDir()
+	baseDir := filepath.Join(dir, "base")
+	c.Assert(os.Mkdir(baseDir, 0755), IsNil)
+	c.Assert(os.Mkdir(filepath.Join(baseDir, "sub"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(baseDir, "inside.txt"), []byte("inside"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "outside.txt"), []byte("outside"), 0644), IsNil)
+	c.Assert(os.Symlink(filepath.Join(dir, "outside.txt"), filepath.Join(baseDir, "link.txt")), IsNil)
+	return dir, baseDir
+}
+
+func (s *exprSuite) Test_EvalPolicy_defaults_to_unrestricted(c *C) {
+	c.Assert(NewScriptEnvironment().GetEvalPolicy().DisableFileAccess, Equals, false)
+	var env *ScriptEnvironment
+	c.Assert(env.GetEvalPolicy().DisableClock, Equals, false)
+	val, err := evalWithPolicy(NewEvalPolicy(), `$__file_exists("builtins_test.go")`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, true)
+	val, err = evalWithPolicy(NewEvalPolicy(), `$func(s) { $__read_file($s.file) }("content")`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "content")
+}
+
+func (s *exprSuite) Test_EvalPolicy_sandbox(c *C) {
+	cases := map[string]string{
+		`$__read_file("builtins_test.go")`:   "File access in 'read_file' is not allowed by the evaluation policy",
+		`$__path_exists("builtins_test.go")`: "File access in 'path_exists' is not allowed by the evaluation policy",
+		`$__file_exists("builtins_test.go")`: "File access in 'file_exists' is not allowed by the evaluation policy",
+		`$__dir_exists("builtins_test.go")`:  "File access in 'dir_exists' is not allowed by the evaluation policy",
+		`$func(p) { $__read_file($p) }("x")`: "File access in 'read_file' is not allowed by the evaluation policy",
+		`$func(s) { $s.file }("content")`:    "File access in 'file' is not allowed by the evaluation policy",
+	}
+	for expr, expected := range cases {
+		_, err := evalWithPolicy(NewSandboxEvalPolicy(), expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, ".*"+expected, Commentf("Expression '%s'", expr))
+	}
+	val, err := evalWithPolicy(NewSandboxEvalPolicy(), `$__timestamp()`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "0")
+}
+
+func (s *exprSuite) Test_EvalPolicy_clock(c *C) {
+	policy := NewEvalPolicy()
+	policy.Clock = func() time.Time {
+		return time.Unix(1500000000, 0)
+	}
+	val, err := evalWithPolicy(policy, `$__timestamp()`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "1500000000")
+
+	policy.DisableClock = true
+	_, err = evalWithPolicy(policy, `$__timestamp()`)
+	c.Assert(err, ErrorMatches, ".*Using the clock in 'timestamp' is not allowed by the evaluation policy")
+}
+
+func (s *exprSuite) Test_EvalPolicy_base_dir(c *C) {
+	dir, baseDir := makePolicyTestDir(c)
+	policy := NewEvalPolicy()
+	policy.BaseDir = baseDir
+	cases := map[string]interface{}{
+		`$__read_file("inside.txt")`:                                             "inside",
+		`$__read_file("sub/../inside.txt")`:                                      "inside",
+		`$__read_file("` + filepath.Join(baseDir, "inside.txt") + `")`:           "inside",
+		`$__file_exists("inside.txt")`:                                           true,
+		`$__file_exists("missing.txt")`:                                          false,
+		`$__dir_exists("sub")`:                                                   true,
+		`$__path_exists("sub")`:                                                  true,
+		`$func(p) { $__read_file($p) }("inside.txt")`:                            "inside",
+		`$__path_exists("` + filepath.Join(baseDir, "sub", "missing.txt") + `")`: false,
+	}
+	for expr, expected := range cases {
+		val, err := evalWithPolicy(policy, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(val, Equals, expected, Commentf("Expression '%s'", expr))
+	}
+	failing := []string{
+		`$__read_file("../outside.txt")`,
+		`$__read_file("` + filepath.Join(dir, "outside.txt") + `")`,
+		`$__read_file("link.txt")`,
+		`$__path_exists("..")`,
+		`$__file_exists("sub/../../outside.txt")`,
+	}
+	for _, expr := range failing {
+		_, err := evalWithPolicy(policy, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err, ErrorMatches, ".*is outside of the base directory", Commentf("Expression '%s'", expr))
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ankyra/escape-core/parsers"
+)
+
+// Turns a Script back into source code. The output is canonical (e.g. method
+// calls are preferred over function calls and only the necessary parentheses
+// are added) and for every Script s returned by ParseScript, ParseScript(Print(s))
+// returns a Script that Equals s.
+//
+// Values that don't have a literal syntax, such as booleans and Go functions,
+// are printed between angle brackets (e.g. "<bool: true>"). This is useful in
+// error messages, but the result can't be parsed.
+func Print(s Script) string {
+	if IsStringAtom(s) {
+		return printStringAtom(ExpectStringAtom(s))
+	}
+	result, _ := printNode(s)
+	if !strings.HasPrefix(result, "$") {
+		// Only expressions starting with '$' are parsed by ParseScript, so
+		// fall back to the function call syntax if we can.
+		if name, args, ok := stdlibCallParts(s); ok && isIdentifier(name) {
+			return printFunctionCall(name, args)
+		}
+	}
+	return result
+}
+
+func printStringAtom(str string) string {
+	if strings.HasPrefix(str, "$$") {
+		return str
+	}
+	if strings.HasPrefix(str, "$") {
+		return printFunctionCall("concat", []Script{LiftString(str)})
+	}
+	return strings.Replace(str, "{{", "\\{{", -1)
+}
+
+// The precedence levels used by the printer are the indices into
+// binaryOperators, followed by the unary '!' and the primary expressions.
+func unaryPrecedence() int {
+	return len(binaryOperators)
+}
+
+func primaryPrecedence() int {
+	return len(binaryOperators) + 1
+}
+
+func printExpression(s Script, precedence int) string {
+	result, prec := printNode(s)
+	if prec < precedence {
+		return "(" + result + ")"
+	}
+	return result
+}
+
+// Returns the source for the Script and its precedence level.
+func printNode(s Script) (string, int) {
+	switch s.(type) {
+	case *stringAtom:
+		return quoteString(ExpectStringAtom(s)), primaryPrecedence()
+	case *integerAtom:
+		return strconv.Itoa(ExpectIntegerAtom(s)), primaryPrecedence()
+	case *floatAtom:
+		return FormatFloat(ExpectFloatAtom(s)), primaryPrecedence()
+	case *list:
+		return "[" + printExpressions(ExpectListAtom(s)) + "]", primaryPrecedence()
+	case *dict:
+		dict := ExpectDictAtom(s)
+		keys := []string{}
+		for key := range dict {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := []string{}
+		for _, key := range keys {
+			items = append(items, quoteString(key)+": "+printExpression(dict[key], 0))
+		}
+		return "{" + strings.Join(items, ", ") + "}", primaryPrecedence()
+	case *lambda:
+		l := ExpectLambdaAtom(s)
+		name := ""
+		if l.Name != "" {
+			name = " " + l.Name
+		}
+		return "$func" + name + "(" + strings.Join(l.Arguments, ", ") + ") { " + printExpression(l.Body, 0) + " }", primaryPrecedence()
+	case *conditional:
+		c := ExpectConditionalAtom(s)
+		return "$if(" + printExpressions([]Script{c.Condition, c.Then, c.Else}) + ")", primaryPrecedence()
+	case *tryExpression:
+		t := ExpectTryAtom(s)
+		return "$try(" + printExpressions([]Script{t.Expression, t.Fallback}) + ")", primaryPrecedence()
+	case *letBinding:
+		return printLet(ExpectLetAtom(s)), primaryPrecedence()
+	case *nullAtom:
+		return "$null", primaryPrecedence()
+	case *safeNavigation:
+		return printSafeNavigation(ExpectSafeNavigationAtom(s)), primaryPrecedence()
+	case *coalescingOperator:
+		c := ExpectCoalesceAtom(s)
+		return printInfix("??", c.Left, c.Right)
+	case *logicalOperator:
+		l := s.(*logicalOperator)
+		return printInfix(l.Operator, l.Left, l.Right)
+	case *apply:
+		return printApply(ExpectApplyAtom(s))
+	case *boolAtom:
+		return fmt.Sprintf("<bool: %v>", ExpectBoolAtom(s)), primaryPrecedence()
+	}
+	return "<" + s.Type().Name() + ">", primaryPrecedence()
+}
+
+func printLet(l *letBinding) string {
+	bindings := []string{}
+	for ix, name := range l.Names {
+		bindings = append(bindings, name+" = "+printExpression(l.Values[ix], 0))
+	}
+	return "$let(" + strings.Join(bindings, ", ") + ") { " + printExpression(l.Body, 0) + " }"
+}
+
+func printSafeNavigation(n *safeNavigation) string {
+	result := printExpression(n.To, primaryPrecedence()) + "?." + n.Field
+	if n.Call {
+		result += "(" + printExpressions(n.Arguments) + ")"
+	}
+	return result
+}
+
+func printExpressions(scripts []Script) string {
+	result := []string{}
+	for _, s := range scripts {
+		result = append(result, printExpression(s, 0))
+	}
+	return strings.Join(result, ", ")
+}
+
+func printInfix(symbol string, left, right Script) (string, int) {
+	for precedence, operators := range binaryOperators {
+		for _, op := range operators {
+			if op.Symbol == symbol {
+				// All operators are left associative.
+				return printExpression(left, precedence) + " " + symbol + " " + printExpression(right, precedence+1), precedence
+			}
+		}
+	}
+	panic("Unknown operator " + symbol)
+}
+
+// Keywords can't be used to look up globals, because "$if" etc. are parsed
+// differently.
+func isKeyword(key string) bool {
+	return key == "func" || key == "if" || key == "let" || key == "null" || key == "try"
+}
+
+// Returns true if the script can be called using "$name(...)", which is the
+// case for global lookups (e.g. of a lambda bound in a let expression) and
+// for the result of such a call.
+func isCallable(s Script) bool {
+	if key, ok := globalLookupKey(s); ok {
+		return isIdentifier(key) && !isKeyword(key) && !strings.HasPrefix(key, "__")
+	}
+	if !IsApplyAtom(s) || isFieldLookup(ExpectApplyAtom(s)) {
+		return false
+	}
+	return isCallable(ExpectApplyAtom(s).To)
+}
+
+// Field lookups are printed using '.' (e.g. "$gcp.outputs").
+func isFieldLookup(f *apply) bool {
+	return len(f.Arguments) == 1 && IsStringAtom(f.Arguments[0]) && isIdentifier(ExpectStringAtom(f.Arguments[0])) && !isEnvLookupFunction(f.To)
+}
+
+func printApply(f *apply) (string, int) {
+	if key, ok := globalLookupKey(f); ok && isIdentifier(key) && !isKeyword(key) {
+		return "$" + key, primaryPrecedence()
+	}
+	if name, args, ok := stdlibCallParts(f); ok && isIdentifier(name) {
+		return printStdlibCall(name, args)
+	}
+	if IsLambdaAtom(f.To) {
+		to, _ := printNode(f.To)
+		return to + "(" + printExpressions(f.Arguments) + ")", primaryPrecedence()
+	}
+	if isFieldLookup(f) {
+		return printExpression(f.To, primaryPrecedence()) + "." + ExpectStringAtom(f.Arguments[0]), primaryPrecedence()
+	}
+	if isCallable(f.To) {
+		to, _ := printNode(f.To)
+		return to + "(" + printExpressions(f.Arguments) + ")", primaryPrecedence()
+	}
+	return "<apply>", primaryPrecedence()
+}
+
+func printStdlibCall(name string, args []Script) (string, int) {
+	if name == "not" && len(args) == 1 {
+		if innerName, innerArgs, ok := stdlibCallParts(args[0]); ok && innerName == "equals" && len(innerArgs) == 2 {
+			return printInfix("!=", innerArgs[0], innerArgs[1])
+		}
+		return "!" + printExpression(args[0], unaryPrecedence()), unaryPrecedence()
+	}
+	if len(args) == 2 {
+		for _, operators := range binaryOperators {
+			for _, op := range operators {
+				if op.FuncName == name {
+					return printInfix(op.Symbol, args[0], args[1])
+				}
+			}
+		}
+	}
+	if len(args) == 0 {
+		return printFunctionCall(name, args), primaryPrecedence()
+	}
+	receiver, ok := printReceiver(args[0])
+	if !ok {
+		return printFunctionCall(name, args), primaryPrecedence()
+	}
+	if name == "list_index" && len(args) == 2 && IsIntegerAtom(args[1]) {
+		return receiver + "[" + Print(args[1]) + "]", primaryPrecedence()
+	}
+	if name == "list_slice" && len(args) == 2 && IsIntegerAtom(args[1]) {
+		return receiver + "[" + Print(args[1]) + ":]", primaryPrecedence()
+	}
+	if name == "list_slice" && len(args) == 3 && IsIntegerAtom(args[1]) && IsIntegerAtom(args[2]) {
+		return receiver + "[" + Print(args[1]) + ":" + Print(args[2]) + "]", primaryPrecedence()
+	}
+	return receiver + "." + name + "(" + printExpressions(args[1:]) + ")", primaryPrecedence()
+}
+
+// Method calls and indexing are only used on expressions starting with '$',
+// so that the result is always a valid script.
+func printReceiver(s Script) (string, bool) {
+	result, precedence := printNode(s)
+	return result, precedence == primaryPrecedence() && strings.HasPrefix(result, "$")
+}
+
+func printFunctionCall(name string, args []Script) string {
+	return "$__" + name + "(" + printExpressions(args) + ")"
+}
+
+func quoteString(str string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + replacer.Replace(str) + `"`
+}
+
+func isIdentifier(str string) bool {
+	ident, rest := parsers.ParseIdent(str)
+	return ident != "" && ident == str && rest == ""
+}
+
+// Returns the function name and arguments if the script is a call to a
+// function in the global environment (e.g. "$__upper($x)" or "$x.upper()").
+func stdlibCallParts(s Script) (string, []Script, bool) {
+	if !IsApplyAtom(s) {
+		return "", nil, false
+	}
+	f := ExpectApplyAtom(s)
+	key, ok := globalLookupKey(f.To)
+	if !ok || !strings.HasPrefix(key, "__") {
+		return "", nil, false
+	}
+	return key[2:], f.Arguments, true
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (p *parserSuite) Test_Print_round_trips(c *C) {
+	cases := []string{
+		``,
+		`plain string`,
+		`$$not an expression {{ $x }}`,
+		`http://{{ $this.inputs.host }}:{{ $this.inputs.port }}/`,
+		`literal \{{ braces }}`,
+		`backslash \\{{ $x }}`,
+		`$this`,
+		`$this.inputs.input_variable`,
+		`$dep-name.outputs.a_b-c`,
+		`$this.inputs.variable.upper()`,
+		`$this.inputs.variable.split(",")`,
+		`$__upper("my string")`,
+		`$__split("my:string", ":")`,
+		`$__timestamp()`,
+		`$__concat("quotes \" and \\ and\nnewlines\tand tabs")`,
+		`$dep.version.split(".")[:2].join(".").concat(".@")`,
+		`$lst[0]`,
+		`$lst[-1]`,
+		`$lst[1:]`,
+		`$lst[0:2]`,
+		`$lst[1:-1]`,
+		`$lst[0].upper()`,
+		`$__id([1, "two", [3], {"four": 4}])`,
+		`$__id({})`,
+		`$__id([])`,
+		`$x + 1 * 2`,
+		`$x * (1 + 2)`,
+		`$x * 0.5 + 2.0`,
+		`$__id([1.5, -0.25, 1.0e-07])`,
+		`$x - 1 - 2`,
+		`$x - (1 - 2)`,
+		`$x - -2`,
+		`$x / 2 % 3`,
+		`$x == 1 && $y != 2 || !$z`,
+		`$x && ($y || $z)`,
+		`$x || $y && $z`,
+		`$x && !($y || $z)`,
+		`$x <= 1 == ($y > 2)`,
+		`$__not($x.equals(1) && $y)`,
+		`$__add(1, $x) * 2`,
+		`$x.concat($y + 1)`,
+		`$__upper($x + 1)`,
+		`$if($x > 1, "a", $y.upper())`,
+		`$if($x, 1, 2).concat("!")`,
+		`$hosts.map($func(h) { $h.concat(".local") })`,
+		`$hosts.reduce($func(acc, h) { $acc + $h.length() }, 0)`,
+		`$func(x, y) { $x + $y }(1, 2)`,
+		`$func() { 1 }()`,
+		`$let(y = $x + 1, z = $y.concat("a")) { $z.upper() }`,
+		`$let(y = 1) { $y }.concat("!")`,
+		`$func fact(n) { $if($n <= 1, 1, $n * $fact($n - 1)) }(5)`,
+		`$let(f = $func(x) { $func(y) { $x + $y } }) { $f(1)(2) }`,
+		`$f(1).upper()`,
+		`$try($x.y, $error.message)`,
+		`$try($__error("a"), 1).concat("b")`,
+		`$p?.outputs?.url ?? "localhost"`,
+		`$p?.outputs.url.upper()`,
+		`$s?.concat("a", 1)?.upper()`,
+		`$x ?? $y || $z`,
+		`($x ?? $y) || $z`,
+		`$x ?? ($y ?? $z)`,
+		`$__id([$null, $x?.a])`,
+		`$__id({"a": 1}).a`,
+		`$__id("a").upper().b`,
+	}
+	for _, testCase := range cases {
+		parsed, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+		printed := Print(parsed)
+		reparsed, err := ParseScript(printed)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s', printed from '%s'", printed, testCase))
+		c.Assert(reparsed.Equals(parsed), Equals, true, Commentf("'%s' printed as '%s'", testCase, printed))
+		c.Assert(Print(reparsed), Equals, printed)
+	}
+}
+
+func (p *parserSuite) Test_Print_is_canonical(c *C) {
+	cases := map[string]string{
+		`$__upper($x)`:                        `$x.upper()`,
+		`$__list_index($x, 1)`:                `$x[1]`,
+		`$x[:2]`:                              `$x[0:2]`,
+		`$x.list_slice(1)`:                    `$x[1:]`,
+		`$__add($x, $__multiply(1, 2))`:       `$x + 1 * 2`,
+		`$__multiply($__add($x, 1), 2)`:       `$__multiply($x + 1, 2)`,
+		`$y + $__multiply($__add($x, 1), 2)`:  `$y + ($x + 1) * 2`,
+		`$__not($__equals($x,1))`:             `$x != 1`,
+		`$__not($x)`:                          `$__not($x)`,
+		`$x == $__not($y)`:                    `$x == !$y`,
+		`$x+(1)`:                              `$x + 1`,
+		`$__concat("a", $x)`:                  `$__concat("a", $x)`,
+		`$__add(1, 2)`:                        `$__add(1, 2)`,
+		`$__id({"b":2,"a":1,})`:               `$__id({"a": 1, "b": 2})`,
+		`$func( x ,y ){$x}`:                   `$func(x, y) { $x }`,
+		`a{{ $x }}b`:                          `$__concat("a", $x, "b")`,
+		`$if( $x,1 ,2 )`:                      `$if($x, 1, 2)`,
+		`$let( y=1 ,z=$y ){$z}`:               `$let(y = 1, z = $y) { $z }`,
+		`$func  f( x ){$f($x)}`:               `$func f(x) { $f($x) }`,
+		`$try( $x ,1 )`:                       `$try($x, 1)`,
+		`$__default($x?.a, $null)`:            `$x?.a.default($null)`,
+		`$x??$y`:                              `$x ?? $y`,
+		`$__id("a")?.upper()`:                 `$__id("a")?.upper()`,
+		`$__concat($x.upper(), "-", $y[0:1])`: `$x.upper().concat("-", $y[0:1])`,
+	}
+	for testCase, expected := range cases {
+		parsed, err := ParseScript(testCase)
+		c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", testCase))
+		c.Assert(Print(parsed), Equals, expected, Commentf("Printing '%s'", testCase))
+	}
+}
+
+func (p *parserSuite) Test_Print_values_without_syntax(c *C) {
+	c.Assert(Print(LiftBool(true)), Equals, "<bool: true>")
+	c.Assert(Print(ShouldLift(strings.ToUpper)), Equals, "<func>")
+	c.Assert(Print(LiftString("$x")), Equals, `$__concat("$x")`)
+	c.Assert(Print(LiftInteger(12)), Equals, "12")
+}
+
+func (p *parserSuite) Test_Equals_on_expressions(c *C) {
+	cases := map[string]bool{
+		`$x.upper()`:               true,
+		`$__upper($x)`:             true,
+		`$x.lower()`:               false,
+		`$y.upper()`:               false,
+		`$x.upper().lower()`:       false,
+		`$func(x) { $x.upper() }`:  false,
+		`$if($x, $x.upper(), 1)`:   false,
+		`$x.upper() && $x.upper()`: false,
+	}
+	parsed := ShouldParse(`$x.upper()`)
+	for testCase, expected := range cases {
+		c.Assert(ShouldParse(testCase).Equals(parsed), Equals, expected, Commentf("Comparing '%s'", testCase))
+	}
+	c.Assert(ShouldParse(`$func(x) { $x.upper() }`).Equals(ShouldParse(`$func(x) { $__upper($x) }`)), Equals, true)
+	c.Assert(ShouldParse(`$func(x) { $x }`).Equals(ShouldParse(`$func(y) { $x }`)), Equals, false)
+	c.Assert(ShouldParse(`$if($x, 1, 2)`).Equals(ShouldParse(`$if($x, 1, 3)`)), Equals, false)
+	c.Assert(ShouldParse(`$let(y = 1) { $y }`).Equals(ShouldParse(`$let(y = 2) { $y }`)), Equals, false)
+	c.Assert(ShouldParse(`$let(y = 1) { $y }`).Equals(ShouldParse(`$let(z = 1) { $y }`)), Equals, false)
+	c.Assert(ShouldParse(`$let(y = 1) { $y }`).Equals(ShouldParse(`$let(y = 1, z = 2) { $y }`)), Equals, false)
+	c.Assert(ShouldParse(`$let(y = 1) { $y.upper() }`).Equals(ShouldParse(`$let(y = 1) { $__upper($y) }`)), Equals, true)
+	c.Assert(ShouldParse(`$x?.a`).Equals(ShouldParse(`$x.a`)), Equals, false)
+	c.Assert(ShouldParse(`$x?.a`).Equals(ShouldParse(`$x?.a()`)), Equals, false)
+	c.Assert(ShouldParse(`$x?.a(1)`).Equals(ShouldParse(`$x?.a(2)`)), Equals, false)
+	c.Assert(ShouldParse(`$x?.a(1)`).Equals(ShouldParse(`$x?.a(1)`)), Equals, true)
+	c.Assert(ShouldParse(`$x ?? $y`).Equals(ShouldParse(`$x || $y`)), Equals, false)
+	c.Assert(ShouldParse(`$x && $y`).Equals(ShouldParse(`$x || $y`)), Equals, false)
+	upper := ShouldLift(strings.ToUpper)
+	c.Assert(upper.Equals(upper), Equals, true)
+	c.Assert(upper.Equals(ShouldLift(strings.ToUpper)), Equals, false)
+}
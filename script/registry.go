@@ -0,0 +1,163 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+)
+
+// A FunctionRegistry holds the functions that are made available to scripts,
+// where they can be called using '$__id(...)' or as a method. Embedding
+// applications can start from the standard library, add their own functions
+// and remove the ones they don't want to expose, without affecting other
+// environments:
+//
+//	registry := NewStdlibFunctionRegistry()
+//	err := registry.Remove("read_file")
+//	err := registry.Register(StdlibFunc{Id: "hello", Func: ..., Args: "v :: string", Returns: "string"})
+//	env := NewScriptEnvironmentWithRegistry(globals, registry)
+type FunctionRegistry struct {
+	functions []StdlibFunc
+
+	// The parsed signatures, keyed on function ID. They are parsed when the
+	// functions are registered, so that type checking doesn't have to.
+	staticTypes map[string]*StaticType
+}
+
+// The functions that the parser uses to implement operators, string
+// interpolation and list indexing. Removing them would break the syntax, so
+// they can't be removed from a registry (but they can be replaced).
+var syntaxFunctions = []string{
+	"add", "subtract", "multiply", "divide", "modulo",
+	"equals", "not", "lt", "lte", "gt", "gte",
+	"concat", "list_index", "list_slice",
+}
+
+// Returns a registry containing the functions that are currently in Stdlib.
+// It's built on every call, so that changes to Stdlib are picked up, but the
+// signatures are only parsed once (see StdlibFunc.GetStaticType).
+func getStdlibFunctionRegistry() *FunctionRegistry {
+	result := NewFunctionRegistry()
+	if err := result.Register(Stdlib...); err != nil {
+		panic(err.Error() + ". This is a bug in Escape.")
+	}
+	return result
+}
+
+// Returns an empty registry. Note that scripts using operators (e.g. '+' or
+// '==') need the functions that implement them to be registered.
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{
+		functions:   []StdlibFunc{},
+		staticTypes: map[string]*StaticType{},
+	}
+}
+
+// Returns a registry containing the standard library.
+func NewStdlibFunctionRegistry() *FunctionRegistry {
+	return getStdlibFunctionRegistry()
+}
+
+func (r *FunctionRegistry) Copy() *FunctionRegistry {
+	result := NewFunctionRegistry()
+	result.functions = append(result.functions, r.functions...)
+	for id, typ := range r.staticTypes {
+		result.staticTypes[id] = typ
+	}
+	return result
+}
+
+// Adds the functions to the registry. A function replaces any registered
+// function with the same ID. Fails if a function doesn't have an ID or an
+// implementation, or if its signature is invalid.
+func (r *FunctionRegistry) Register(funcs ...StdlibFunc) error {
+	types := []*StaticType{}
+	for _, f := range funcs {
+		if f.Id == "" {
+			return fmt.Errorf("Missing function ID in function registry")
+		}
+		if f.Func == nil {
+			return fmt.Errorf("Missing implementation for function '%s' in function registry", f.Id)
+		}
+		typ, err := f.GetStaticType()
+		if err != nil {
+			return err
+		}
+		types = append(types, typ)
+	}
+	for i, f := range funcs {
+		if ix := r.indexOf(f.Id); ix >= 0 {
+			r.functions[ix] = f
+		} else {
+			r.functions = append(r.functions, f)
+		}
+		r.staticTypes[f.Id] = types[i]
+	}
+	return nil
+}
+
+// Removes the functions with the given IDs. Unknown IDs are ignored. Fails,
+// without removing anything, if one of the functions is needed by the syntax
+// (e.g. "add", which implements '+').
+func (r *FunctionRegistry) Remove(ids ...string) error {
+	for _, id := range ids {
+		for _, syntaxFunc := range syntaxFunctions {
+			if id == syntaxFunc {
+				return fmt.Errorf("Function '%s' is used by the script syntax and can't be removed from the function registry", id)
+			}
+		}
+	}
+	for _, id := range ids {
+		if ix := r.indexOf(id); ix >= 0 {
+			r.functions = append(r.functions[:ix], r.functions[ix+1:]...)
+			delete(r.staticTypes, id)
+		}
+	}
+	return nil
+}
+
+func (r *FunctionRegistry) Lookup(id string) (StdlibFunc, bool) {
+	if ix := r.indexOf(id); ix >= 0 {
+		return r.functions[ix], true
+	}
+	return StdlibFunc{}, false
+}
+
+// Returns the registered functions, in the order they were registered.
+func (r *FunctionRegistry) Functions() []StdlibFunc {
+	result := []StdlibFunc{}
+	return append(result, r.functions...)
+}
+
+// Returns the static types of the registered functions, keyed on the names
+// used to look them up in the global environment (e.g. "__upper").
+func (r *FunctionRegistry) GetStaticTypes() map[string]*StaticType {
+	result := map[string]*StaticType{}
+	for id, typ := range r.staticTypes {
+		result["__"+id] = typ
+	}
+	return result
+}
+
+func (r *FunctionRegistry) indexOf(id string) int {
+	for ix, f := range r.functions {
+		if f.Id == id {
+			return ix
+		}
+	}
+	return -1
+}
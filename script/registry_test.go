@@ -0,0 +1,155 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func hello(env *ScriptEnvironment, inputValues []Script) (Script, error) {
+	return LiftString("hello " + ExpectStringAtom(inputValues[0])), nil
+}
+
+var helloFunc = StdlibFunc{Id: "hello", Func: LiftFunction(hello), Doc: "Say hello", ActsOn: "strings", Args: "v :: string", Returns: "string"}
+
+func (s *exprSuite) Test_FunctionRegistry_Stdlib(c *C) {
+	registry := NewStdlibFunctionRegistry()
+	c.Assert(registry.Functions(), HasLen, len(Stdlib))
+	f, ok := registry.Lookup("upper")
+	c.Assert(ok, Equals, true)
+	c.Assert(f.Id, Equals, "upper")
+	_, ok = registry.Lookup("hello")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Register_and_Remove(c *C) {
+	registry := NewStdlibFunctionRegistry()
+	c.Assert(registry.Register(helloFunc), IsNil)
+	c.Assert(registry.Remove("read_file", "unknown"), IsNil)
+
+	env := NewScriptEnvironmentWithRegistry(nil, registry)
+	val, err := ParseAndEvalToGoValue(`$__hello("world").upper()`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "HELLO WORLD")
+	val, err = ParseAndEvalToGoValue(`$__concat("a").hello()`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "hello a")
+	_, err = ParseAndEvalToGoValue(`$__read_file("/etc/hostname")`, env)
+	c.Assert(err, ErrorMatches, ".*Field '__read_file' was not found.*")
+
+	// The standard library and other registries are unaffected.
+	_, ok := NewStdlibFunctionRegistry().Lookup("read_file")
+	c.Assert(ok, Equals, true)
+	_, err = ParseAndEvalToGoValue(`$__hello("world")`, NewScriptEnvironmentWithGlobals(nil))
+	c.Assert(err, ErrorMatches, ".*Field '__hello' was not found.*")
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Register_replaces_function_with_same_id(c *C) {
+	registry := NewStdlibFunctionRegistry()
+	upper := helloFunc
+	upper.Id = "upper"
+	c.Assert(registry.Register(upper), IsNil)
+	c.Assert(registry.Functions(), HasLen, len(Stdlib))
+	val, err := ParseAndEvalToGoValue(`$__upper("world")`, NewScriptEnvironmentWithRegistry(nil, registry))
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "hello world")
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Register_fails_on_invalid_functions(c *C) {
+	registry := NewFunctionRegistry()
+	invalid := []StdlibFunc{
+		StdlibFunc{Func: LiftFunction(hello)},
+		StdlibFunc{Id: "hello"},
+		StdlibFunc{Id: "hello", Func: LiftFunction(hello), Args: "v string"},
+	}
+	for _, f := range invalid {
+		c.Assert(registry.Register(helloFunc, f), Not(IsNil))
+	}
+	c.Assert(registry.Functions(), HasLen, 0)
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Remove_refuses_syntax_functions(c *C) {
+	registry := NewStdlibFunctionRegistry()
+	for _, id := range []string{"add", "concat", "not", "equals", "list_index"} {
+		err := registry.Remove("read_file", id)
+		c.Assert(err, ErrorMatches, "Function '"+id+"' is used by the script syntax and can't be removed from the function registry")
+	}
+	_, ok := registry.Lookup("read_file")
+	c.Assert(ok, Equals, true)
+	env := NewScriptEnvironmentWithRegistry(nil, registry)
+	val, err := ParseAndEvalToGoValue(`{{ $__id([1 + 2])[0] }}`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "3")
+}
+
+func (s *exprSuite) Test_FunctionRegistry_GetStaticTypes(c *C) {
+	registry := NewStdlibFunctionRegistry()
+	c.Assert(registry.GetStaticTypes(), HasLen, len(Stdlib))
+	c.Assert(registry.Register(helloFunc), IsNil)
+	c.Assert(registry.Remove("upper"), IsNil)
+	types := registry.Copy().GetStaticTypes()
+	expected, err := helloFunc.GetStaticType()
+	c.Assert(err, IsNil)
+	c.Assert(types["__hello"], DeepEquals, expected)
+	_, ok := types["__upper"]
+	c.Assert(ok, Equals, false)
+	c.Assert(NewStdlibFunctionRegistry().GetStaticTypes()["__upper"], NotNil)
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Stdlib_picks_up_changes_to_Stdlib(c *C) {
+	original := Stdlib
+	defer func() { Stdlib = original }()
+	Stdlib = append(append([]StdlibFunc{}, original...), helloFunc)
+	_, ok := NewStdlibFunctionRegistry().Lookup("hello")
+	c.Assert(ok, Equals, true)
+	script, err := ParseScript(`$__hello("world")`)
+	c.Assert(err, IsNil)
+	typ, err := TypeCheck(script, map[string]*StaticType{})
+	c.Assert(err, IsNil)
+	c.Assert(typ.Name, Equals, "string")
+}
+
+func (s *exprSuite) Test_FunctionRegistry_Copy(c *C) {
+	registry := NewFunctionRegistry()
+	c.Assert(registry.Register(helloFunc), IsNil)
+	copied := registry.Copy()
+	c.Assert(copied.Remove("hello"), IsNil)
+	c.Assert(registry.Functions(), HasLen, 1)
+	c.Assert(copied.Functions(), HasLen, 0)
+}
+
+func (s *exprSuite) Test_TypeCheckWithRegistry(c *C) {
+	registry := NewFunctionRegistry()
+	c.Assert(registry.Register(helloFunc), IsNil)
+	parsed, err := ParseScript(`$__hello("world")`)
+	c.Assert(err, IsNil)
+	typ, err := TypeCheckWithRegistry(parsed, nil, registry)
+	c.Assert(err, IsNil)
+	c.Assert(typ.String(), Equals, "string")
+
+	parsed, err = ParseScript(`$__hello(1)`)
+	c.Assert(err, IsNil)
+	_, err = TypeCheckWithRegistry(parsed, nil, registry)
+	c.Assert(err, ErrorMatches, "'hello' expects string for argument 1, got integer")
+
+	parsed, err = ParseScript(`$__upper("world")`)
+	c.Assert(err, IsNil)
+	_, err = TypeCheckWithRegistry(parsed, nil, registry)
+	c.Assert(strings.Contains(err.Error(), "'__upper' was not found"), Equals, true, Commentf("%s", err))
+}
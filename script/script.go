@@ -37,7 +37,7 @@ func EvalToGoValue(script Script, env *ScriptEnvironment) (interface{}, error) {
 }
 
 func ParseAndEvalToGoValue(scriptStr string, env *ScriptEnvironment) (interface{}, error) {
-	parsed, err := ParseScript(scriptStr)
+	parsed, err := ParseScriptCached(scriptStr)
 	if err != nil {
 		return "", err
 	}
@@ -49,7 +49,7 @@ func ParseAndEvalToGoValue(scriptStr string, env *ScriptEnvironment) (interface{
 }
 
 func ParseAndEvalToString(scriptStr string, env *ScriptEnvironment) (string, error) {
-	parsed, err := ParseScript(scriptStr)
+	parsed, err := ParseScriptCached(scriptStr)
 	if err != nil {
 		return "", err
 	}
@@ -71,5 +71,8 @@ func ParseAndEvalToString(scriptStr string, env *ScriptEnvironment) (string, err
 		}
 		return strconv.Itoa(v.(int)), nil
 	}
+	if val.Type().IsFloat() {
+		return FormatFloat(ExpectFloatAtom(val)), nil
+	}
 	return "", fmt.Errorf("Expression '%s' did not return a string value", scriptStr)
 }
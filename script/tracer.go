@@ -0,0 +1,206 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Tracer records the function applications performed while a script is
+// evaluated, together with their arguments and results. Tracing is opt-in;
+// see ScriptEnvironment.SetTracer.
+type Tracer struct {
+	// The top-level applications. Nested applications (e.g. the
+	// evaluation of arguments, or of a lambda's body) are children.
+	Calls []*TraceNode
+
+	sensitive       []string
+	sensitiveValues map[string]bool
+	stack           []*TraceNode
+}
+
+type TraceNode struct {
+	// The expression being evaluated, printed as source code.
+	Expression string
+
+	// The evaluated arguments.
+	Arguments []string
+
+	// The result, or the error if the application failed.
+	Result string
+	Error  string
+
+	Children []*TraceNode
+
+	isApplication bool
+}
+
+const tracerKey = "__tracer"
+
+const maskedValue = "******"
+
+// Sensitive values that are shorter than this are only masked when they make
+// up a whole value, because masking them everywhere (e.g. every "1" in the
+// trace) would make the trace unreadable.
+const minMaskedSubstringLength = 4
+
+func NewTracer() *Tracer {
+	return &Tracer{
+		Calls:           []*TraceNode{},
+		sensitive:       []string{},
+		sensitiveValues: map[string]bool{},
+		stack:           []*TraceNode{},
+	}
+}
+
+// Masks all occurrences of the value in the recorded expressions, arguments
+// and results. Used for sensitive variables (see
+// ScriptEnvironment.AddSensitiveValue). Short values are only masked when
+// they make up a whole argument or result.
+func (t *Tracer) MaskValue(value string) {
+	if value == "" {
+		return
+	}
+	t.sensitiveValues[value] = true
+	if len(value) >= minMaskedSubstringLength {
+		t.sensitive = append(t.sensitive, value)
+	}
+}
+
+func (t *Tracer) maskScript(s Script) {
+	if IsListAtom(s) {
+		for _, item := range ExpectListAtom(s) {
+			t.maskScript(item)
+		}
+	} else if IsDictAtom(s) {
+		for _, item := range ExpectDictAtom(s) {
+			t.maskScript(item)
+		}
+	} else if IsStringAtom(s) {
+		t.MaskValue(ExpectStringAtom(s))
+	} else if IsIntegerAtom(s) || IsFloatAtom(s) {
+		t.MaskValue(printExpression(s, 0))
+	}
+}
+
+// Renders the evaluation tree, one application per line.
+func (t *Tracer) Render() string {
+	lines := []string{}
+	for _, node := range t.Calls {
+		lines = node.render(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (n *TraceNode) render(lines []string, indent string) []string {
+	line := indent + n.Expression
+	if len(n.Arguments) > 0 {
+		line += " with (" + strings.Join(n.Arguments, ", ") + ")"
+	}
+	if n.Error != "" {
+		line += " => error: " + n.Error
+	} else {
+		line += " => " + n.Result
+	}
+	lines = append(lines, line)
+	for _, child := range n.Children {
+		lines = child.render(lines, indent+"  ")
+	}
+	return lines
+}
+
+func (t *Tracer) enter(f *apply) {
+	t.stack = append(t.stack, &TraceNode{
+		Expression: t.mask(Print(f)),
+		Arguments:  []string{},
+		Children:   []*TraceNode{},
+	})
+}
+
+// Marks the current node as a function application. Applications that turn
+// out to be environment or dict lookups are not recorded.
+func (t *Tracer) recordApplication(to Script, args []Script) {
+	if t == nil || len(t.stack) == 0 || isEnvLookupFunction(to) {
+		return
+	}
+	if !to.Type().IsFunc() && !to.Type().IsLambda() {
+		return
+	}
+	node := t.stack[len(t.stack)-1]
+	node.isApplication = true
+	for _, arg := range args {
+		node.Arguments = append(node.Arguments, t.formatValue(arg))
+	}
+}
+
+func (t *Tracer) exit(result Script, err error) {
+	node := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	nodes := node.Children
+	if node.isApplication {
+		if err != nil {
+			node.Error = t.mask(err.Error())
+		} else {
+			node.Result = t.formatValue(result)
+		}
+		nodes = []*TraceNode{node}
+	}
+	if len(t.stack) == 0 {
+		t.Calls = append(t.Calls, nodes...)
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Children = append(parent.Children, nodes...)
+	}
+}
+
+func (t *Tracer) formatValue(s Script) string {
+	if IsBoolAtom(s) {
+		return fmt.Sprintf("%v", ExpectBoolAtom(s))
+	}
+	if IsStringAtom(s) && t.sensitiveValues[ExpectStringAtom(s)] {
+		return printExpression(LiftString(maskedValue), 0)
+	}
+	str := printExpression(s, 0)
+	if t.sensitiveValues[str] {
+		return maskedValue
+	}
+	return t.mask(str)
+}
+
+func (t *Tracer) mask(str string) string {
+	for _, value := range t.sensitive {
+		str = strings.Replace(str, value, maskedValue, -1)
+	}
+	return str
+}
+
+// The tracer is stored in the ScriptEnvironment, which means it needs to be a
+// Script, but it can't be referenced from scripts.
+func (t *Tracer) Eval(env *ScriptEnvironment) (Script, error) {
+	return t, nil
+}
+func (t *Tracer) Value() (interface{}, error) {
+	return t, nil
+}
+func (t *Tracer) Type() ValueType {
+	return NewType("tracer")
+}
+func (t *Tracer) Equals(s2 Script) bool {
+	t2, ok := s2.(*Tracer)
+	return ok && t == t2
+}
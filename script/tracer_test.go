@@ -0,0 +1,106 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func evalWithTracer(c *C, expr string) (*Tracer, interface{}, error) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"x":      LiftString("abc"),
+		"secret": LiftString("hunter2"),
+		"lst":    ShouldLift([]interface{}{1, 2}),
+	})
+	tracer := NewTracer()
+	tracer.MaskValue("hunter2")
+	env.SetTracer(tracer)
+	val, err := ParseAndEvalToGoValue(expr, env)
+	return tracer, val, err
+}
+
+func (s *exprSuite) Test_Tracer_records_applications(c *C) {
+	tracer, val, err := evalWithTracer(c, `$x.upper().concat("!")`)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "ABC!")
+	c.Assert(tracer.Calls, HasLen, 1)
+	call := tracer.Calls[0]
+	c.Assert(call.Expression, Equals, `$x.upper().concat("!")`)
+	c.Assert(call.Arguments, DeepEquals, []string{`"ABC"`, `"!"`})
+	c.Assert(call.Result, Equals, `"ABC!"`)
+	c.Assert(call.Error, Equals, "")
+	c.Assert(call.Children, HasLen, 1)
+	c.Assert(call.Children[0].Expression, Equals, `$x.upper()`)
+	c.Assert(call.Children[0].Arguments, DeepEquals, []string{`"abc"`})
+	c.Assert(call.Children[0].Result, Equals, `"ABC"`)
+	c.Assert(call.Children[0].Children, HasLen, 0)
+}
+
+func (s *exprSuite) Test_Tracer_Render(c *C) {
+	cases := map[string]string{
+		`$x`: ``,
+		`$lst.map($func(i) { $i * 2 })`: `$lst.map($func(i) { $i * 2 }) with ([1, 2], $func(i) { $i * 2 }) => [2, 4]
+  $func(i) { $i * 2 }(1) with (1) => 2
+    $i * 2 with (1, 2) => 2
+  $func(i) { $i * 2 }(2) with (2) => 4
+    $i * 2 with (2, 2) => 4`,
+		`$if($x == "abc", $x.length() > 1, $x.fail())`: `$x == "abc" with ("abc", "abc") => true
+$x.length() > 1 with (3, 1) => true
+  $x.length() with ("abc") => 3`,
+		`$x.concat($secret)`: `$x.concat($secret) with ("abc", "******") => "abc******"`,
+		`$x.list_index(1)`:   `$x[1] with ("abc", 1) => error: Expecting list argument in list index call, but got 'string'`,
+	}
+	for expr, expected := range cases {
+		tracer, _, _ := evalWithTracer(c, expr)
+		c.Assert(tracer.Render(), Equals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Tracer_masks_sensitive_values_in_environment(c *C) {
+	env := NewScriptEnvironmentWithGlobals(map[string]Script{
+		"password": LiftString("hunter2"),
+		"pin":      LiftInteger(12),
+		"short":    LiftString("ab"),
+		"keys":     ShouldLift([]interface{}{"s3cr3t-key"}),
+	})
+	env.AddSensitiveValue(LiftString("hunter2"))
+	env.AddSensitiveValue(LiftString("ab"))
+	tracer := NewTracer()
+	env.SetTracer(tracer)
+	env.AddSensitiveValue(LiftInteger(12))
+	env.AddSensitiveValue(ShouldLift([]interface{}{"s3cr3t-key"}))
+	cases := map[string]string{
+		`$password.concat("!")`: `$password.concat("!") with ("******", "!") => "******!"`,
+		`$short.concat("c")`:    `$short.concat("c") with ("******", "c") => "abc"`,
+		`$__upper("abc")`:       `$__upper("abc") with ("abc") => "ABC"`,
+		`$pin + 100`:            `$pin + 100 with (******, 100) => 112`,
+		`$keys[0].concat("-", $pin)`: `$keys[0].concat("-", $pin) with ("******", "-", ******) => "******-12"
+  $keys[0] with (["******"], 0) => "******"`,
+	}
+	for expr, expected := range cases {
+		tracer.Calls = []*TraceNode{}
+		_, err := ParseAndEvalToGoValue(expr, env)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(tracer.Render(), Equals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Tracer_is_disabled_by_default(c *C) {
+	c.Assert(NewScriptEnvironment().GetTracer(), IsNil)
+	var env *ScriptEnvironment
+	c.Assert(env.GetTracer(), IsNil)
+}
@@ -20,11 +20,13 @@ type ValueType interface {
 	Name() string
 	IsFunc() bool
 	IsInteger() bool
+	IsFloat() bool
 	IsList() bool
 	IsBool() bool
 	IsMap() bool
 	IsString() bool
 	IsLambda() bool
+	IsNull() bool
 }
 
 /*
@@ -52,6 +54,9 @@ func (typ *valueType) IsString() bool {
 func (typ *valueType) IsInteger() bool {
 	return typ.Type == "integer"
 }
+func (typ *valueType) IsFloat() bool {
+	return typ.Type == "float"
+}
 func (typ *valueType) IsBool() bool {
 	return typ.Type == "bool"
 }
@@ -61,3 +66,15 @@ func (typ *valueType) IsList() bool {
 func (typ *valueType) IsLambda() bool {
 	return typ.Type == "lambda"
 }
+func (typ *valueType) IsNull() bool {
+	return typ.Type == "null"
+}
+
+// Returns the type that both values have, or "*" if they have different
+// types. Used for expressions that evaluate to one of two values.
+func commonValueType(a, b ValueType) ValueType {
+	if a.Name() == b.Name() {
+		return a
+	}
+	return NewType("*")
+}
@@ -0,0 +1,574 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+   Static types
+*/
+
+// A StaticType describes the type of an expression before it is evaluated.
+// The Name is one of "string", "integer", "float", "bool", "list", "map",
+// "func", "lambda", "null", or "*" when the type can't be known statically. Names can
+// be combined into a union using "|" (e.g. "list|string").
+type StaticType struct {
+	Name string
+
+	// The element type of a list, if known.
+	Elem *StaticType
+
+	// The known fields of a map. A nil map means the fields are unknown.
+	Fields map[string]*StaticType
+
+	// The parameters of a function. A nil slice means the signature is
+	// unknown and any arguments are accepted.
+	Params []*StaticType
+
+	// The number of trailing parameters that are optional.
+	Optional int
+
+	// Whether the last parameter can be repeated.
+	Variadic bool
+
+	// The type of the value returned by a function.
+	Returns *StaticType
+}
+
+func NewStaticType(name string) *StaticType {
+	return &StaticType{Name: name}
+}
+
+func AnyStaticType() *StaticType {
+	return NewStaticType("*")
+}
+
+func NewListStaticType(elem *StaticType) *StaticType {
+	return &StaticType{Name: "list", Elem: elem}
+}
+
+func NewMapStaticType(fields map[string]*StaticType) *StaticType {
+	return &StaticType{Name: "map", Fields: fields}
+}
+
+func NewFuncStaticType(params []*StaticType, returns *StaticType) *StaticType {
+	return &StaticType{Name: "func", Params: params, Returns: returns}
+}
+
+func (t *StaticType) IsAny() bool {
+	return t == nil || t.Name == "*"
+}
+
+func (t *StaticType) String() string {
+	if t.IsAny() {
+		return "*"
+	}
+	if t.Name == "list" && !t.Elem.IsAny() {
+		return "list[" + t.Elem.String() + "]"
+	}
+	return t.Name
+}
+
+// Returns true if a value of type typ can be used where t is expected.
+func (t *StaticType) Accepts(typ *StaticType) bool {
+	if t.IsAny() || typ.IsAny() {
+		return true
+	}
+	for _, expected := range strings.Split(t.Name, "|") {
+		for _, actual := range strings.Split(typ.Name, "|") {
+			if expected == actual {
+				return true
+			}
+			if expected == "func" && actual == "lambda" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *StaticType) getReturnType() *StaticType {
+	if t.Returns == nil {
+		return AnyStaticType()
+	}
+	return t.Returns
+}
+
+func unifyStaticTypes(t1, t2 *StaticType) *StaticType {
+	if t1.IsAny() || t2.IsAny() || t1.String() != t2.String() {
+		return AnyStaticType()
+	}
+	return t1
+}
+
+// Parses a parameter list in the format used by StdlibFunc.Args, e.g.
+// "lst :: list, sep :: string", "v :: string|integer, ..." or
+// "lst :: list, i :: integer, [j :: integer]".
+func ParseFuncStaticType(args, returns string) (*StaticType, error) {
+	result := NewFuncStaticType([]*StaticType{}, AnyStaticType())
+	if returns != "" {
+		result.Returns = NewStaticType(returns)
+	}
+	if strings.TrimSpace(args) == "" {
+		return result, nil
+	}
+	for _, param := range strings.Split(args, ",") {
+		param = strings.TrimSpace(param)
+		if param == "..." {
+			if len(result.Params) == 0 {
+				return nil, fmt.Errorf("Expecting parameter before '...' in '%s'", args)
+			}
+			result.Variadic = true
+			continue
+		}
+		optional := strings.HasPrefix(param, "[") && strings.HasSuffix(param, "]")
+		if optional {
+			param = param[1 : len(param)-1]
+			result.Optional++
+		} else if result.Optional > 0 || result.Variadic {
+			return nil, fmt.Errorf("Required parameter '%s' can't follow optional parameters in '%s'", param, args)
+		}
+		parts := strings.Split(param, "::")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("Expecting 'name :: type', got '%s' in '%s'", param, args)
+		}
+		result.Params = append(result.Params, NewStaticType(strings.TrimSpace(parts[1])))
+	}
+	return result, nil
+}
+
+func (f StdlibFunc) GetStaticType() (*StaticType, error) {
+	sig := parsedSignature{f.Args, f.Returns}
+	if result, ok := parsedSignatures.Load(sig); ok {
+		return result.(*StaticType), nil
+	}
+	result, err := ParseFuncStaticType(f.Args, f.Returns)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signature for '%s': %s", f.Id, err.Error())
+	}
+	parsedSignatures.Store(sig, result)
+	return result, nil
+}
+
+// The static types of the signatures that have been parsed by
+// StdlibFunc.GetStaticType. The same types are returned for the same
+// signature, so they must not be modified.
+var parsedSignatures sync.Map
+
+type parsedSignature struct {
+	Args, Returns string
+}
+
+// Returns the static type of an already evaluated value.
+func InferStaticType(s Script) *StaticType {
+	if IsStringAtom(s) || IsIntegerAtom(s) || IsFloatAtom(s) || IsBoolAtom(s) || IsNullAtom(s) {
+		return NewStaticType(s.Type().Name())
+	}
+	if IsListAtom(s) {
+		return NewListStaticType(unifyListStaticTypes(ExpectListAtom(s), InferStaticType))
+	}
+	if IsDictAtom(s) {
+		fields := map[string]*StaticType{}
+		for key, val := range ExpectDictAtom(s) {
+			fields[key] = InferStaticType(val)
+		}
+		return NewMapStaticType(fields)
+	}
+	if IsFunctionAtom(s) {
+		return NewStaticType("func")
+	}
+	if IsLambdaAtom(s) {
+		return NewStaticType("lambda")
+	}
+	return AnyStaticType()
+}
+
+func unifyListStaticTypes(lst []Script, infer func(Script) *StaticType) *StaticType {
+	if len(lst) == 0 {
+		return AnyStaticType()
+	}
+	result := infer(lst[0])
+	for _, item := range lst[1:] {
+		result = unifyStaticTypes(result, infer(item))
+	}
+	return result
+}
+
+/*
+   Type checker
+*/
+
+// Infers the type of the script without evaluating it. The globals describe
+// the values that can be looked up using '$' (e.g. "this", or the names of
+// dependencies); the signatures of the standard library are added
+// automatically. An error is returned for the first type error found.
+func TypeCheck(s Script, globals map[string]*StaticType) (*StaticType, error) {
+	return TypeCheckWithRegistry(s, globals, getStdlibFunctionRegistry())
+}
+
+// Like TypeCheck, but checks against the signatures of the functions in the
+// registry instead of the standard library.
+func TypeCheckWithRegistry(s Script, globals map[string]*StaticType, registry *FunctionRegistry) (*StaticType, error) {
+	env := map[string]*StaticType{}
+	for key, val := range globals {
+		env[key] = val
+	}
+	for key, typ := range registry.GetStaticTypes() {
+		env[key] = typ
+	}
+	return typeCheck(s, env)
+}
+
+func typeCheck(s Script, env map[string]*StaticType) (*StaticType, error) {
+	switch s.(type) {
+	case *list:
+		var err error
+		result := unifyListStaticTypes(ExpectListAtom(s), func(item Script) *StaticType {
+			if err != nil {
+				return AnyStaticType()
+			}
+			var typ *StaticType
+			typ, err = typeCheck(item, env)
+			return typ
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewListStaticType(result), nil
+	case *dict:
+		fields := map[string]*StaticType{}
+		for key, val := range ExpectDictAtom(s) {
+			typ, err := typeCheck(val, env)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = typ
+		}
+		return NewMapStaticType(fields), nil
+	case *lambda:
+		return typeCheckLambda(s.(*lambda), nil, env)
+	case *conditional:
+		return typeCheckConditional(s.(*conditional), env)
+	case *tryExpression:
+		return typeCheckTry(s.(*tryExpression), env)
+	case *letBinding:
+		return typeCheckLet(s.(*letBinding), env)
+	case *nullAtom:
+		return NewStaticType("null"), nil
+	case *safeNavigation:
+		return typeCheckSafeNavigation(s.(*safeNavigation), env)
+	case *coalescingOperator:
+		c := s.(*coalescingOperator)
+		left, err := typeCheck(c.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		right, err := typeCheck(c.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		if left.Name == "null" {
+			return right, nil
+		}
+		return unifyStaticTypes(left, right), nil
+	case *logicalOperator:
+		op := s.(*logicalOperator)
+		for _, operand := range []Script{op.Left, op.Right} {
+			typ, err := typeCheck(operand, env)
+			if err != nil {
+				return nil, err
+			}
+			if !NewStaticType("bool").Accepts(typ) {
+				return nil, fmt.Errorf("'%s' expects bool arguments, got %s", op.Operator, typ)
+			}
+		}
+		return NewStaticType("bool"), nil
+	case *apply:
+		return typeCheckApply(s.(*apply), env)
+	}
+	return InferStaticType(s), nil
+}
+
+func typeCheckConditional(c *conditional, env map[string]*StaticType) (*StaticType, error) {
+	cond, err := typeCheck(c.Condition, env)
+	if err != nil {
+		return nil, err
+	}
+	if !NewStaticType("bool").Accepts(cond) {
+		return nil, fmt.Errorf("Expecting bool condition in if expression, got %s", cond)
+	}
+	then, err := typeCheck(c.Then, env)
+	if err != nil {
+		return nil, err
+	}
+	els, err := typeCheck(c.Else, env)
+	if err != nil {
+		return nil, err
+	}
+	return unifyStaticTypes(then, els), nil
+}
+
+// The fallback is used when the expression fails at runtime, but type errors
+// in the expression are still reported.
+func typeCheckTry(t *tryExpression, env map[string]*StaticType) (*StaticType, error) {
+	newEnv := map[string]*StaticType{}
+	for key, val := range env {
+		newEnv[key] = val
+	}
+	newEnv[tryErrorVariable] = NewMapStaticType(map[string]*StaticType{
+		"message":    NewStaticType("string"),
+		"expression": NewStaticType("string"),
+	})
+	fallback, err := typeCheck(t.Fallback, newEnv)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := typeCheck(t.Expression, env)
+	if err != nil {
+		return nil, err
+	}
+	return unifyStaticTypes(expr, fallback), nil
+}
+
+func typeCheckLet(l *letBinding, env map[string]*StaticType) (*StaticType, error) {
+	newEnv := map[string]*StaticType{}
+	for key, val := range env {
+		newEnv[key] = val
+	}
+	for ix, name := range l.Names {
+		typ, err := typeCheck(l.Values[ix], newEnv)
+		if err != nil {
+			return nil, err
+		}
+		newEnv[name] = typ
+	}
+	return typeCheck(l.Body, newEnv)
+}
+
+// Fields that are known to be missing result in null. Otherwise the lookup or
+// method call is checked as if '.' was used.
+func typeCheckSafeNavigation(n *safeNavigation, env map[string]*StaticType) (*StaticType, error) {
+	// Globals that don't exist evaluate to null (see safeNavigation.evalTarget).
+	if key, ok := globalLookupKey(n.To); ok {
+		if _, found := env[key]; !found {
+			return NewStaticType("null"), nil
+		}
+	}
+	to, err := typeCheck(n.To, env)
+	if err != nil {
+		return nil, err
+	}
+	if to.Name == "null" {
+		return to, nil
+	}
+	if n.Call {
+		return typeCheck(newStdlibCall(n.Field, append([]Script{n.To}, n.Arguments...)), env)
+	}
+	if to.Name == "map" && to.Fields != nil {
+		if result, ok := to.Fields[n.Field]; ok {
+			return result, nil
+		}
+		return NewStaticType("null"), nil
+	}
+	return typeCheck(NewApply(n.To, []Script{LiftString(n.Field)}), env)
+}
+
+// Type checks the body of the lambda. If args is nil the lambda is not being
+// applied and the parameters can have any type.
+func typeCheckLambda(l *lambda, args []*StaticType, env map[string]*StaticType) (*StaticType, error) {
+	if args != nil && len(args) != len(l.Arguments) {
+		return nil, fmt.Errorf("Argument arity mismatch. Expecting %d arguments, got %d.", len(l.Arguments), len(args))
+	}
+	newEnv := map[string]*StaticType{}
+	for key, val := range env {
+		newEnv[key] = val
+	}
+	if l.Name != "" {
+		// The parameter and return types of a recursive call are unknown.
+		newEnv[l.Name] = NewStaticType("lambda")
+	}
+	params := []*StaticType{}
+	for ix, variable := range l.Arguments {
+		typ := AnyStaticType()
+		if args != nil {
+			typ = args[ix]
+		}
+		newEnv[variable] = typ
+		params = append(params, typ)
+	}
+	body, err := typeCheck(l.Body, newEnv)
+	if err != nil {
+		return nil, err
+	}
+	if args != nil {
+		return body, nil
+	}
+	return &StaticType{Name: "lambda", Params: params, Returns: body}, nil
+}
+
+func typeCheckApply(f *apply, env map[string]*StaticType) (*StaticType, error) {
+	if isEnvLookupFunction(f.To) {
+		if len(f.Arguments) == 1 && IsStringAtom(f.Arguments[0]) && ExpectStringAtom(f.Arguments[0]) == "$" {
+			return NewMapStaticType(env), nil
+		}
+		return AnyStaticType(), nil
+	}
+	args := []*StaticType{}
+	for _, arg := range f.Arguments {
+		typ, err := typeCheck(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, typ)
+	}
+	if IsLambdaAtom(f.To) {
+		return typeCheckLambda(ExpectLambdaAtom(f.To), args, env)
+	}
+	to, err := typeCheck(f.To, env)
+	if err != nil {
+		return nil, err
+	}
+	if to.IsAny() {
+		return AnyStaticType(), nil
+	}
+	if NewStaticType("func").Accepts(to) {
+		name, _ := globalLookupKey(f.To)
+		return typeCheckFuncApply(strings.TrimPrefix(name, "__"), to, args)
+	}
+	if NewStaticType("map").Accepts(to) {
+		return typeCheckDictApply(to, f.Arguments, args)
+	}
+	if NewStaticType("string").Accepts(to) {
+		return NewStaticType("string"), nil
+	}
+	return nil, fmt.Errorf("Expecting function, map or string for apply, but got '%s'", to)
+}
+
+func typeCheckFuncApply(name string, fun *StaticType, args []*StaticType) (*StaticType, error) {
+	if name == "" {
+		name = "function"
+	} else {
+		name = "'" + name + "'"
+	}
+	if fun.Params == nil {
+		return fun.getReturnType(), nil
+	}
+	min := len(fun.Params) - fun.Optional
+	if fun.Variadic {
+		min--
+	}
+	if len(args) < min || (!fun.Variadic && len(args) > len(fun.Params)) {
+		expected := fmt.Sprintf("%d", min)
+		if fun.Variadic {
+			expected = fmt.Sprintf("at least %d", min)
+		} else if min != len(fun.Params) {
+			expected = fmt.Sprintf("%d to %d", min, len(fun.Params))
+		}
+		return nil, fmt.Errorf("%s expects %s argument(s), got %d", name, expected, len(args))
+	}
+	for ix, arg := range args {
+		param := fun.Params[len(fun.Params)-1]
+		if ix < len(fun.Params) {
+			param = fun.Params[ix]
+		}
+		if !param.Accepts(arg) {
+			return nil, fmt.Errorf("%s expects %s for argument %d, got %s", name, param, ix+1, arg)
+		}
+	}
+	return numericReturnType(fun.getReturnType(), args), nil
+}
+
+// Arithmetic functions return an integer when all their arguments are
+// integers and a float when one of them is a float.
+func numericReturnType(returns *StaticType, args []*StaticType) *StaticType {
+	if returns.Name != "integer|float" {
+		return returns
+	}
+	allIntegers := true
+	for _, arg := range args {
+		if arg.Name == "float" {
+			return NewStaticType("float")
+		}
+		allIntegers = allIntegers && arg.Name == "integer"
+	}
+	if allIntegers {
+		return NewStaticType("integer")
+	}
+	return returns
+}
+
+func typeCheckDictApply(dict *StaticType, argScripts []Script, args []*StaticType) (*StaticType, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("Expecting one argument in dict lookup call, but got '%d'", len(args))
+	}
+	if !NewStaticType("string").Accepts(args[0]) {
+		return nil, fmt.Errorf("Expecting string argument in dict lookup call, but got '%s'", args[0])
+	}
+	if dict.Fields == nil || !IsStringAtom(argScripts[0]) {
+		return AnyStaticType(), nil
+	}
+	key := ExpectStringAtom(argScripts[0])
+	result, ok := dict.Fields[key]
+	if !ok {
+		keys := []string{}
+		for k := range dict.Fields {
+			if !strings.HasPrefix(k, "__") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		expects := strings.Join(keys, ", ")
+		if len(keys) == 0 {
+			expects = "target collection was empty"
+		}
+		return nil, fmt.Errorf("Field '%s' was not found (%s)", key, expects)
+	}
+	return result, nil
+}
+
+func isEnvLookupFunction(s Script) bool {
+	if !IsFunctionAtom(s) {
+		return false
+	}
+	return reflect.ValueOf(ExpectFunctionAtom(s)).Pointer() == reflect.ValueOf(builtinEnvLookup).Pointer()
+}
+
+// Returns the key if the script is a lookup in the global environment (e.g.
+// "$gcp" or the "$__concat" in "$__concat(...)").
+func globalLookupKey(s Script) (string, bool) {
+	if !IsApplyAtom(s) {
+		return "", false
+	}
+	lookup := ExpectApplyAtom(s)
+	if len(lookup.Arguments) != 1 || !IsStringAtom(lookup.Arguments[0]) || !IsApplyAtom(lookup.To) {
+		return "", false
+	}
+	globals := ExpectApplyAtom(lookup.To)
+	if !isEnvLookupFunction(globals.To) || len(globals.Arguments) != 1 || !IsStringAtom(globals.Arguments[0]) {
+		return "", false
+	}
+	if ExpectStringAtom(globals.Arguments[0]) != "$" {
+		return "", false
+	}
+	return ExpectStringAtom(lookup.Arguments[0]), true
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package script
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func typeCheckGlobals() map[string]*StaticType {
+	return map[string]*StaticType{
+		"this": NewMapStaticType(map[string]*StaticType{
+			"version": NewStaticType("string"),
+			"inputs": NewMapStaticType(map[string]*StaticType{
+				"name":     NewStaticType("string"),
+				"replicas": NewStaticType("integer"),
+				"debug":    NewStaticType("bool"),
+				"hosts":    NewListStaticType(NewStaticType("string")),
+			}),
+		}),
+		"dep": NewMapStaticType(nil),
+	}
+}
+
+func typeCheckExpr(c *C, expr string) (*StaticType, error) {
+	script, err := ParseScript(expr)
+	c.Assert(err, IsNil, Commentf("Couldn't parse '%s'", expr))
+	return TypeCheck(script, typeCheckGlobals())
+}
+
+func (s *exprSuite) Test_TypeCheck(c *C) {
+	cases := map[string]string{
+		`plain string`:                                               "string",
+		`$this.version`:                                              "string",
+		`$this.inputs.name.upper()`:                                  "string",
+		`$this.inputs.replicas + 1`:                                  "integer",
+		`$this.inputs.replicas > 1 && $this.inputs.debug`:            "bool",
+		`$this.inputs.hosts`:                                         "list[string]",
+		`$this.inputs.hosts[0]`:                                      "*",
+		`$this.inputs.hosts.join(",")`:                               "string",
+		`$this.inputs.hosts.length()`:                                "integer",
+		`$this.inputs.replicas.concat("x")`:                          "string",
+		`$__concat("a", 1, "b")`:                                     "string",
+		`$this.version.split(".")[:2].join(".").concat(".@")`:        "string",
+		`$this.inputs.hosts.map($func(h) { $h.upper() })`:            "list",
+		`$func(x) { $x + 1 }(2)`:                                     "integer",
+		`$if($this.inputs.debug, "a", "b")`:                          "string",
+		`$if($this.inputs.debug, "a", 1)`:                            "*",
+		`$dep.outputs.anything.upper()`:                              "string",
+		`$let(n = $this.inputs.name, l = $n.length()) { $l + 1 }`:    "integer",
+		`$let(this = 1) { $this }`:                                   "integer",
+		`$let(f = $func(x) { $x + 1 }) { $f(1) }`:                    "integer",
+		`$func f(n) { $if($n == 0, 0, $f($n - 1)) }(3)`:              "*",
+		`$__now().add_duration("1h")`:                                "string",
+		`$__format("%s:%d", $this.inputs.name, 1)`:                   "string",
+		`$__format("none")`:                                          "string",
+		`$this.inputs.name.has_prefix("a")`:                          "bool",
+		`$this.version.version_matches(">= 2")`:                      "bool",
+		`$try($__read_file("missing.txt"), "x")`:                     "string",
+		`$try($this.inputs.name, $error.message)`:                    "string",
+		`$try($this.inputs.name, 1)`:                                 "*",
+		`$this.inputs?.name`:                                         "string",
+		`$this.inputs?.unknown`:                                      "null",
+		`$this.inputs?.unknown ?? "x"`:                               "string",
+		`$this.inputs.name ?? "x"`:                                   "string",
+		`$this.inputs.name ?? 1`:                                     "*",
+		`$this.inputs?.name?.upper()`:                                "string",
+		`$dep?.outputs?.url`:                                         "*",
+		`$missing?.url`:                                              "null",
+		`$missing?.outputs?.url ?? "x"`:                              "string",
+		`$missing?.upper()`:                                          "null",
+		`$null`:                                                      "null",
+		`$this.inputs?.unknown.default(1)`:                           "*",
+		`$__length([1, "a"])`:                                        "integer",
+		`$__id({"a": 1}).a`:                                          "*",
+		`http://{{ $this.inputs.name }}:{{ $this.inputs.replicas }}`: "string",
+	}
+	for expr, expected := range cases {
+		typ, err := typeCheckExpr(c, expr)
+		c.Assert(err, IsNil, Commentf("Expression '%s'", expr))
+		c.Assert(typ.String(), Equals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_TypeCheck_fails(c *C) {
+	cases := map[string]string{
+		`$this.inputs.replicas.upper()`:                               "'upper' expects string for argument 1, got integer",
+		`$this.inputs.name + 1`:                                       "'add' expects integer|float for argument 1, got string",
+		`$this.inputs.name.split()`:                                   "'split' expects 2 argument(s), got 1",
+		`$this.inputs.hosts.list_slice()`:                             "'list_slice' expects 2 to 3 argument(s), got 1",
+		`$__concat("a", $this.inputs.debug)`:                          "'concat' expects string|integer|float for argument 2, got bool",
+		`$this.inputs.debug.length()`:                                 "'length' expects list|string for argument 1, got bool",
+		`$this.inputs.unknown`:                                        "Field 'unknown' was not found (debug, hosts, name, replicas)",
+		`$unknown`:                                                    "Field 'unknown' was not found (dep, this)",
+		`$this.inputs.replicas && $this.inputs.debug`:                 "'&&' expects bool arguments, got integer",
+		`$if($this.inputs.name, 1, 2)`:                                "Expecting bool condition in if expression, got string",
+		`$if($this.inputs.debug, $this.inputs.name.lower(), 1 + "a")`: "'add' expects integer|float for argument 2, got string",
+		`$this.inputs.hosts.map($func(h) { $h.upper(1) })`:            "'upper' expects 1 argument(s), got 2",
+		`$func(x) { $x.upper() }(2)`:                                  "'upper' expects string for argument 1, got integer",
+		`$let(n = $this.inputs.replicas) { $n.upper() }`:              "'upper' expects string for argument 1, got integer",
+		`$let(n = $this.inputs.name + 1) { $n }`:                      "'add' expects integer|float for argument 1, got string",
+		`$try($this.inputs.name, $error.unknown)`:                     "Field 'unknown' was not found (expression, message)",
+		`$try($this.inputs.unknown, "x")`:                             "Field 'unknown' was not found (debug, hosts, name, replicas)",
+		`$try($this.inputs.replicas.upper(), "x")`:                    "'upper' expects string for argument 1, got integer",
+		`$this.inputs.replicas.pad_left(3)`:                           "'pad_left' expects string for argument 1, got integer",
+		`$this.inputs?.replicas?.upper()`:                             "'upper' expects string for argument 1, got integer",
+		`$this.inputs?.unknown.upper()`:                               "'upper' expects string for argument 1, got null",
+		`$missing.url`:                                                "Field 'missing' was not found (dep, this)",
+		`$this.version?.upper(1)`:                                     "'upper' expects 1 argument(s), got 2",
+		`$func(x) { $x }(1, 2)`:                                       "Argument arity mismatch. Expecting 1 arguments, got 2.",
+		`$this.inputs[1]`:                                             "'list_index' expects list for argument 1, got map",
+		`$__concat(["a"])`:                                            "'concat' expects string|integer|float for argument 1, got list[string]",
+		`prefix-{{ $this.inputs.name.upper(2) }}`:                     "'upper' expects 1 argument(s), got 2",
+	}
+	for expr, expected := range cases {
+		_, err := typeCheckExpr(c, expr)
+		c.Assert(err, Not(IsNil), Commentf("Expression '%s'", expr))
+		c.Assert(err.Error(), Equals, expected, Commentf("Expression '%s'", expr))
+	}
+}
+
+func (s *exprSuite) Test_Stdlib_signatures_are_valid(c *C) {
+	for _, f := range Stdlib {
+		_, err := f.GetStaticType()
+		c.Assert(err, IsNil, Commentf("Function '%s'", f.Id))
+	}
+}
+
+func (s *exprSuite) Test_ParseFuncStaticType(c *C) {
+	typ, err := ParseFuncStaticType("lst :: list, i :: integer, [j :: integer]", "list")
+	c.Assert(err, IsNil)
+	c.Assert(typ.Params, HasLen, 3)
+	c.Assert(typ.Optional, Equals, 1)
+	c.Assert(typ.Variadic, Equals, false)
+	c.Assert(typ.Returns.String(), Equals, "list")
+
+	typ, err = ParseFuncStaticType("v :: string|integer, ...", "")
+	c.Assert(err, IsNil)
+	c.Assert(typ.Params, HasLen, 1)
+	c.Assert(typ.Variadic, Equals, true)
+	c.Assert(typ.Returns.IsAny(), Equals, true)
+
+	_, err = ParseFuncStaticType("[i :: integer], j :: integer", "")
+	c.Assert(err, Not(IsNil))
+	_, err = ParseFuncStaticType("i integer", "")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *exprSuite) Test_TypeCheck_apply_fails_on_non_function(c *C) {
+	script := NewApply(LiftBool(true), []Script{LiftString("x")})
+	_, err := TypeCheck(script, nil)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, "Expecting function, map or string for apply, but got 'bool'")
+}
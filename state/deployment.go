@@ -17,6 +17,8 @@ limitations under the License.
 package state
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -30,12 +32,21 @@ type DeploymentState struct {
 	Release     string                 `json:"release,omitempty"`
 	Stages      map[string]*StageState `json:"stages,omitempty"`
 	Inputs      map[string]interface{} `json:"inputs,omitempty"`
+	Secret      string                 `json:"secret,omitempty"`
 	environment *EnvironmentState      `json:"-"`
 	parent      *DeploymentState       `json:"-"`
 	parentStage *StageState            `json:"-"`
 }
 
 func NewDeploymentState(env *EnvironmentState, name, release string) (*DeploymentState, error) {
+	d, err := newDeploymentState(env, name, release)
+	if err != nil {
+		return d, err
+	}
+	return d, d.GenerateSecret()
+}
+
+func newDeploymentState(env *EnvironmentState, name, release string) (*DeploymentState, error) {
 	d := &DeploymentState{
 		Name:        name,
 		Release:     release,
@@ -51,13 +62,27 @@ func (d *DeploymentState) GetName() string {
 }
 
 func (d *DeploymentState) Summarize() *DeploymentState {
-	result, _ := NewDeploymentState(d.environment, d.Name, d.Release)
+	result, _ := newDeploymentState(d.environment, d.Name, d.Release)
 	for name, stage := range d.Stages {
 		result.Stages[name] = stage.Summarize()
 	}
 	return result
 }
 
+// Sets a new random secret, which keys the seeded values returned by the
+// random_string and password script functions. New deployments get a secret
+// on creation; deployments that were saved without one need to call this
+// (and save the state) before they can use these functions. Note that
+// replacing an existing secret changes all the seeded values.
+func (d *DeploymentState) GenerateSecret() error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("Couldn't generate deployment secret: %s", err.Error())
+	}
+	d.Secret = hex.EncodeToString(secret)
+	return nil
+}
+
 func (d *DeploymentState) GetRootDeploymentName() string {
 	prev := d
 	p := prev
@@ -68,6 +68,25 @@ func (s *suite) Test_Deployment_NewDeploymentState(c *C) {
 	c.Assert(d.environment, IsNil)
 }
 
+func (s *suite) Test_Deployment_NewDeploymentState_generates_secret(c *C) {
+	d, err := NewDeploymentState(nil, "name", "project/application")
+	c.Assert(err, IsNil)
+	c.Assert(d.Secret, HasLen, 64)
+	d2, err := NewDeploymentState(nil, "name", "project/application")
+	c.Assert(err, IsNil)
+	c.Assert(d2.Secret, Not(Equals), d.Secret)
+	c.Assert(d.Summarize().Secret, Equals, "")
+}
+
+func (s *suite) Test_Deployment_GenerateSecret(c *C) {
+	d, err := NewDeploymentState(nil, "name", "project/application")
+	c.Assert(err, IsNil)
+	secret := d.Secret
+	c.Assert(d.GenerateSecret(), IsNil)
+	c.Assert(d.Secret, HasLen, 64)
+	c.Assert(d.Secret, Not(Equals), secret)
+}
+
 func (s *suite) Test_Deployment_validateAndFix_fixes_nils(c *C) {
 	d, err := NewDeploymentState(nil, "name", "project/application")
 	c.Assert(err, IsNil)
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ankyra/escape-core"
 	"github.com/ankyra/escape-core/script"
+	"github.com/ankyra/escape-core/variables"
 )
 
 type DeploymentResolver interface {
@@ -44,11 +45,12 @@ func ToScriptEnvironment(d *DeploymentState, metadata *core.ReleaseMetadata, sta
 	if d == nil {
 		return nil, MissingDeploymentStateError()
 	}
-	result, err := newStateCompiler(context).Compile(d, metadata, stage)
+	st := newStateCompiler(context)
+	result, err := st.Compile(d, metadata, stage)
 	if err != nil {
 		return nil, err
 	}
-	return script.NewScriptEnvironmentWithGlobals(script.ExpectDictAtom(result)), nil
+	return st.newScriptEnvironment(d, result), nil
 }
 
 func ToScriptEnvironmentForDependencyStep(d *DeploymentState, metadata *core.ReleaseMetadata, stage string, context DeploymentResolver) (*script.ScriptEnvironment, error) {
@@ -61,7 +63,18 @@ func ToScriptEnvironmentForDependencyStep(d *DeploymentState, metadata *core.Rel
 	if err != nil {
 		return nil, err
 	}
-	return script.NewScriptEnvironmentWithGlobals(script.ExpectDictAtom(result)), nil
+	return st.newScriptEnvironment(d, result), nil
+}
+
+func (s *stateCompiler) newScriptEnvironment(d *DeploymentState, globals script.Script) *script.ScriptEnvironment {
+	env := script.NewScriptEnvironmentWithGlobals(script.ExpectDictAtom(globals))
+	if d.Secret != "" {
+		env.SetSecret(d.Secret)
+	}
+	for _, value := range s.SensitiveValues {
+		env.AddSensitiveValue(value)
+	}
+	return env
 }
 
 func newResolverFromMap(metaMap map[string]*core.ReleaseMetadata) DeploymentResolver {
@@ -80,6 +93,7 @@ type stateCompiler struct {
 	Result                       map[string]script.Script
 	Resolver                     DeploymentResolver
 	DependencyInputsAreAvailable bool
+	SensitiveValues              []script.Script
 }
 
 func newStateCompiler(context DeploymentResolver) *stateCompiler {
@@ -87,6 +101,7 @@ func newStateCompiler(context DeploymentResolver) *stateCompiler {
 		Result:                       map[string]script.Script{},
 		Resolver:                     context,
 		DependencyInputsAreAvailable: true,
+		SensitiveValues:              []script.Script{},
 	}
 }
 
@@ -167,14 +182,14 @@ func (s *stateCompiler) compileState(d *DeploymentState, metadata *core.ReleaseM
 		for key, val := range d.GetCalculatedInputs(stage) {
 			for _, defined := range metadata.GetInputs(stage) {
 				if key == defined.Id {
-					inputs[key] = val
+					inputs[key] = s.compileVariableValue(defined, val)
 				}
 			}
 		}
 		for key, val := range d.GetCalculatedOutputs(stage) {
 			for _, defined := range metadata.GetOutputs(stage) {
 				if key == defined.Id {
-					outputs[key] = val
+					outputs[key] = s.compileVariableValue(defined, val)
 				}
 			}
 		}
@@ -187,3 +202,44 @@ func (s *stateCompiler) compileState(d *DeploymentState, metadata *core.ReleaseM
 	result["deployment"] = script.LiftString(d.GetDeploymentPath())
 	return script.LiftDict(result)
 }
+
+// Lifts the value and records it if the variable is sensitive, so that it can
+// be masked when scripts are traced.
+func (s *stateCompiler) compileVariableValue(v *variables.Variable, val interface{}) script.Script {
+	result := script.ShouldLift(liftVariableValue(v, val))
+	if v.Sensitive {
+		s.SensitiveValues = append(s.SensitiveValues, result)
+	}
+	return result
+}
+
+// The values in the deployment state are decoded from JSON, which doesn't
+// distinguish between 2 and 2.0, so float variables (and lists of floats) are
+// lifted explicitly to make sure they don't end up as integers.
+func liftVariableValue(v *variables.Variable, val interface{}) interface{} {
+	if v.Type == "list" && v.Options["type"] == "float" {
+		lst, ok := val.([]interface{})
+		if !ok {
+			return val
+		}
+		result := []interface{}{}
+		for _, item := range lst {
+			result = append(result, liftFloat(item))
+		}
+		return result
+	}
+	if v.Type != "float" {
+		return val
+	}
+	return liftFloat(val)
+}
+
+func liftFloat(val interface{}) interface{} {
+	switch val.(type) {
+	case float64:
+		return script.LiftFloat(val.(float64))
+	case int:
+		return script.LiftFloat(float64(val.(int)))
+	}
+	return val
+}
@@ -45,6 +45,18 @@ func (s *scriptSuite) SetUpTest(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// Returns a new deployment in a freshly loaded environment, so that tests can
+// modify it without affecting the shared fixtures.
+func newTestScriptDeployment(c *C, name string) *DeploymentState {
+	prj, err := NewProjectStateFromFile("prj", "testdata/project_script.json", nil)
+	c.Assert(err, IsNil)
+	env, err := prj.GetEnvironmentStateOrMakeNew("dev")
+	c.Assert(err, IsNil)
+	d, err := env.GetOrCreateDeploymentState(name)
+	c.Assert(err, IsNil)
+	return d
+}
+
 func (s *scriptSuite) Test_ToScript(c *C) {
 	metadata := core.NewReleaseMetadata("test", "1.0")
 	metadata.Metadata["value"] = "yo"
@@ -61,6 +73,71 @@ func (s *scriptSuite) Test_ToScript(c *C) {
 	test_helper_check_script_environment(c, unit, dicts, "archive-release")
 }
 
+func (s *scriptSuite) Test_ToScript_lifts_float_variables_to_floats(c *C) {
+	metadata := core.NewReleaseMetadata("test", "1.0")
+	for id, typ := range map[string]string{"ratio": "float", "replicas": "integer"} {
+		input, err := variables.NewVariableFromString(id, typ)
+		c.Assert(err, IsNil)
+		metadata.AddInputVariable(input)
+	}
+	d := newTestScriptDeployment(c, "new-deployment")
+	d.GetStageOrCreateNew(DeployStage).Inputs = map[string]interface{}{
+		"ratio":    2.0,
+		"replicas": 3.0,
+	}
+	unit := newStateCompiler(nil).compileState(d, metadata, DeployStage, true)
+	inputs := script.ExpectDictAtom(script.ExpectDictAtom(unit)["inputs"])
+	c.Assert(script.IsFloatAtom(inputs["ratio"]), Equals, true)
+	c.Assert(script.ExpectFloatAtom(inputs["ratio"]), Equals, 2.0)
+	c.Assert(script.IsIntegerAtom(inputs["replicas"]), Equals, true)
+	c.Assert(script.ExpectIntegerAtom(inputs["replicas"]), Equals, 3)
+}
+
+func (s *scriptSuite) Test_ToScriptEnvironment_masks_sensitive_inputs_in_trace(c *C) {
+	metadata := core.NewReleaseMetadata("test", "1.0")
+	password, err := variables.NewVariableFromDict(map[interface{}]interface{}{
+		"id":        "password",
+		"sensitive": true,
+	})
+	c.Assert(err, IsNil)
+	user, err := variables.NewVariableFromString("user", "string")
+	c.Assert(err, IsNil)
+	metadata.AddInputVariable(password)
+	metadata.AddInputVariable(user)
+	d := newTestScriptDeployment(c, "new-deployment")
+	d.GetStageOrCreateNew(BuildStage).Inputs = map[string]interface{}{
+		"password": "hunter2",
+		"user":     "admin",
+	}
+	env, err := ToScriptEnvironment(d, metadata, BuildStage, nil)
+	c.Assert(err, IsNil)
+	tracer := script.NewTracer()
+	env.SetTracer(tracer)
+	val, err := script.ParseAndEvalToString(`$this.inputs.user.concat(":", $this.inputs.password)`, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "admin:hunter2")
+	c.Assert(tracer.Render(), Equals, `$this.inputs.user.concat(":", $this.inputs.password) with ("admin", ":", "******") => "admin:******"`)
+}
+
+func (s *scriptSuite) Test_ToScript_lifts_float_list_items_to_floats(c *C) {
+	metadata := core.NewReleaseMetadata("test", "1.0")
+	input, err := variables.NewVariableFromString("ratios", "list")
+	c.Assert(err, IsNil)
+	input.Options = map[string]interface{}{"type": "float"}
+	metadata.AddInputVariable(input)
+	d := newTestScriptDeployment(c, "new-deployment")
+	d.GetStageOrCreateNew(DeployStage).Inputs = map[string]interface{}{
+		"ratios": []interface{}{2.0, 0.5},
+	}
+	unit := newStateCompiler(nil).compileState(d, metadata, DeployStage, true)
+	inputs := script.ExpectDictAtom(script.ExpectDictAtom(unit)["inputs"])
+	ratios := script.ExpectListAtom(inputs["ratios"])
+	c.Assert(ratios, HasLen, 2)
+	c.Assert(script.IsFloatAtom(ratios[0]), Equals, true)
+	c.Assert(script.ExpectFloatAtom(ratios[0]), Equals, 2.0)
+	c.Assert(script.ExpectFloatAtom(ratios[1]), Equals, 0.5)
+}
+
 func (s *scriptSuite) Test_ToScript_doesnt_include_variable_that_are_not_defined_in_release_metadata(c *C) {
 	metadata := core.NewReleaseMetadata("test", "1.0")
 	unit := newStateCompiler(nil).compileState(depl, metadata, DeployStage, true)
@@ -162,6 +239,35 @@ func (s *scriptSuite) Test_ToScriptEnvironment_fails_if_dependency_metadata_is_m
 	c.Assert(err, Not(IsNil))
 }
 
+func (s *scriptSuite) Test_ToScriptEnvironment_sets_deployment_secret(c *C) {
+	metadata := core.NewReleaseMetadata("test", "1.0")
+	d := newTestScriptDeployment(c, "new-deployment")
+	secret := d.Secret
+	c.Assert(secret, Not(Equals), "")
+	env, err := ToScriptEnvironment(d, metadata, BuildStage, nil)
+	c.Assert(err, IsNil)
+	password, err := script.ParseAndEvalToString(`$__password(16, "seed")`, env)
+	c.Assert(err, IsNil)
+
+	env, err = ToScriptEnvironment(d, metadata, BuildStage, nil)
+	c.Assert(err, IsNil)
+	c.Assert(d.Secret, Equals, secret)
+	samePassword, err := script.ParseAndEvalToString(`$__password(16, "seed")`, env)
+	c.Assert(err, IsNil)
+	c.Assert(samePassword, Equals, password)
+}
+
+func (s *scriptSuite) Test_ToScriptEnvironment_doesnt_generate_deployment_secret(c *C) {
+	metadata := core.NewReleaseMetadata("test", "1.0")
+	d := newTestScriptDeployment(c, "new-deployment")
+	d.Secret = ""
+	env, err := ToScriptEnvironment(d, metadata, BuildStage, nil)
+	c.Assert(err, IsNil)
+	c.Assert(d.Secret, Equals, "")
+	_, err = script.ParseAndEvalToString(`$__password(16, "seed")`, env)
+	c.Assert(err, ErrorMatches, "Can't derive seeded value in password call, because the environment doesn't have a secret")
+}
+
 func (s *scriptSuite) Test_ToScriptEnvironment_adds_consumers(c *C) {
 	resolver := newResolverFromMap(map[string]*core.ReleaseMetadata{
 		"archive-full-v1.0": core.NewReleaseMetadata("test", "1.0"),
@@ -19,9 +19,37 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
+// The largest integer that can be represented exactly by a float64.
+const MaxExactFloatInteger = 1 << 53
+
+// Returns true if the float has no fractional part and can be converted to an
+// int without losing precision.
+func IsWholeNumber(f float64) bool {
+	return f == math.Trunc(f) && math.Abs(f) <= MaxExactFloatInteger
+}
+
+// Formats the float so that it always contains a decimal point and can't be
+// mistaken for an integer, e.g. "2.0", "0.5" or "1.0e+21".
+func FormatFloat(f float64) string {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		format = 'g'
+	}
+	str := strconv.FormatFloat(f, format, -1, 64)
+	if strings.ContainsAny(str, ".IN") {
+		return str
+	}
+	if strings.Contains(str, "e") {
+		return strings.Replace(str, "e", ".0e", 1)
+	}
+	return str + ".0"
+}
+
 func InterfaceMapToStringMap(values *map[string]interface{}, keyPrefix string) map[string]string {
 	result := map[string]string{}
 	if values == nil {
@@ -48,7 +76,14 @@ func InterfaceToString(val interface{}) (string, error) {
 			stringVal = "1"
 		}
 	case float64:
-		stringVal = strconv.Itoa(int(val.(float64)))
+		// Decoded JSON uses float64 for every number, so whole numbers are
+		// formatted as integers.
+		f := val.(float64)
+		if IsWholeNumber(f) {
+			stringVal = strconv.Itoa(int(f))
+		} else {
+			stringVal = FormatFloat(f)
+		}
 	case int:
 		stringVal = strconv.Itoa(val.(int))
 	case []interface{}:
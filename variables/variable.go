@@ -54,7 +54,7 @@ type Variable struct {
 	// The variable type. Before executing any steps Escape will make sure that
 	// all the values match the types that are set on the variables.
 	//
-	// One of: `string`, `list`, `integer`, `bool`.
+	// One of: `string`, `list`, `integer`, `float`, `bool`.
 	//
 	// Default: `string`
 	Type string `json:"type"`
@@ -196,6 +196,9 @@ func (v *Variable) AskUserInput() interface{} {
 	if v.Type == "integer" {
 		return 0
 	}
+	if v.Type == "float" {
+		return 0.0
+	}
 	if v.Type == "bool" {
 		return false
 	}
@@ -283,9 +286,112 @@ func (v *Variable) parseEvalAndGetValue(str string, env *script.ScriptEnvironmen
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't run expression in default field of variable '%s': %s in '%s'", v.Id, err.Error(), str)
 	}
+	// Floats are converted to strings here, because the string type can't
+	// tell float64 values apart from whole numbers decoded from JSON.
+	if f, ok := result.(float64); ok && v.Type == "string" {
+		return script.FormatFloat(f), nil
+	}
 	return result, nil
 }
 
+// Returns the static type of the variable's value, which is used to type
+// check scripts referencing this variable.
+func (v *Variable) GetStaticType() *script.StaticType {
+	switch v.Type {
+	case "integer", "float", "bool":
+		return script.NewStaticType(v.Type)
+	case "list":
+		elem := "string"
+		if typ, ok := v.Options["type"].(string); ok && typ != "" {
+			elem = typ
+		}
+		return script.NewListStaticType(script.NewStaticType(elem))
+	}
+	return script.NewStaticType("string")
+}
+
+// Parses the expressions in the default and items fields into the script
+// cache, so that they don't have to be parsed when the variable is evaluated.
+func (v *Variable) CompileScripts() error {
+	for _, field := range []struct {
+		Name  string
+		Value interface{}
+	}{{"default", v.Default}, {"items", v.Items}} {
+		strs := []string{}
+		switch field.Value.(type) {
+		case (*string):
+			strs = append(strs, *field.Value.(*string))
+		case string:
+			strs = append(strs, field.Value.(string))
+		case []interface{}:
+			for _, k := range field.Value.([]interface{}) {
+				if str, ok := k.(string); ok {
+					strs = append(strs, str)
+				}
+			}
+		}
+		for _, str := range strs {
+			if _, err := script.ParseScriptCached(str); err != nil {
+				return fmt.Errorf("Couldn't parse expression in %s field of variable '%s': %s in '%s'", field.Name, v.Id, err.Error(), str)
+			}
+		}
+	}
+	return nil
+}
+
+// Type checks the expressions in the default field, without evaluating them.
+func (v *Variable) TypeCheckDefault(globals map[string]*script.StaticType) error {
+	switch v.Default.(type) {
+	case (*string):
+		return v.typeCheckExpression(*v.Default.(*string), v.getAcceptedStaticType(), globals)
+	case string:
+		return v.typeCheckExpression(v.Default.(string), v.getAcceptedStaticType(), globals)
+	case []interface{}:
+		for _, k := range v.Default.([]interface{}) {
+			if str, ok := k.(string); ok {
+				if err := v.typeCheckExpression(str, script.AnyStaticType(), globals); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Variable) typeCheckExpression(str string, expected *script.StaticType, globals map[string]*script.StaticType) error {
+	parsed, err := script.ParseScriptCached(str)
+	if err != nil {
+		return fmt.Errorf("Couldn't parse expression in default field of variable '%s': %s in '%s'", v.Id, err.Error(), str)
+	}
+	if script.IsStringAtom(parsed) {
+		return nil
+	}
+	typ, err := script.TypeCheck(parsed, globals)
+	if err != nil {
+		return fmt.Errorf("Type error in default field of variable '%s': %s in '%s'", v.Id, err.Error(), str)
+	}
+	if !expected.Accepts(typ) {
+		return fmt.Errorf("Expecting %s in default field of variable '%s', but expression has type %s in '%s'", v.Type, v.Id, typ, str)
+	}
+	return nil
+}
+
+// The types the default expression can evaluate to, taking into account the
+// conversions performed by the variable type's validator.
+func (v *Variable) getAcceptedStaticType() *script.StaticType {
+	switch v.Type {
+	case "integer":
+		return script.NewStaticType("integer|string")
+	case "float":
+		return script.NewStaticType("float|integer|string")
+	case "bool":
+		return script.NewStaticType("bool|integer|string")
+	case "list":
+		return script.NewStaticType("list|string")
+	}
+	return script.AnyStaticType()
+}
+
 func (v *Variable) validateOneOf(env *script.ScriptEnvironment, item interface{}) (interface{}, error) {
 	items := v.Items
 	return v.validateOneOfInterface(env, item, items)
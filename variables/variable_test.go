@@ -325,6 +325,54 @@ func (s *variableSuite) Test_Integer_Variable_Expects_Integer_Value_Or_Convertab
 	c.Assert(val, Equals, 12)
 }
 
+func (s *variableSuite) Test_GetValue_Float_Variable(c *C) {
+	unit, err := NewVariableFromString("test", "float")
+	c.Assert(err, IsNil)
+	for _, value := range []interface{}{0.5, "0.5"} {
+		variableCtx := map[string]interface{}{
+			"test": value,
+		}
+		val, err := unit.GetValue(&variableCtx, nil)
+		c.Assert(err, IsNil)
+		c.Assert(val, Equals, 0.5)
+	}
+	variableCtx := map[string]interface{}{
+		"test": 2,
+	}
+	val, err := unit.GetValue(&variableCtx, nil)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, 2.0)
+}
+
+func (s *variableSuite) Test_GetValue_Float_Variable_Default_Expression(c *C) {
+	unit, err := NewVariableFromString("test", "float")
+	c.Assert(err, IsNil)
+	unit.Default = "$__divide(1, 4.0)"
+	val, err := unit.GetValue(nil, script.NewScriptEnvironmentWithGlobals(nil))
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, 0.25)
+}
+
+func (s *variableSuite) Test_GetValue_String_Variable_formats_floats_like_scripts(c *C) {
+	env := script.NewScriptEnvironmentWithGlobals(nil)
+	unit, err := NewVariableFromString("test", "string")
+	c.Assert(err, IsNil)
+	for _, expr := range []string{"$__add(1.5, 0.5)", "$__divide(1, 4.0)"} {
+		unit.Default = expr
+		val, err := unit.GetValue(nil, env)
+		c.Assert(err, IsNil)
+		str, err := script.ParseAndEvalToString(expr, env)
+		c.Assert(err, IsNil)
+		c.Assert(val, Equals, str)
+	}
+	val, err := unit.GetValue(&map[string]interface{}{"test": 2.0}, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "2")
+	val, err = unit.GetValue(&map[string]interface{}{"test": 1.5e-7}, env)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "1.5e-07")
+}
+
 func (s *variableSuite) Test_GetValue_List_Variable(c *C) {
 	unit, err := NewVariableFromString("test", "list")
 	c.Assert(err, IsNil)
@@ -354,3 +402,60 @@ func (s *variableSuite) Test_Variable_InScope(c *C) {
 	c.Assert(unit.InScope("build"), Equals, true)
 	c.Assert(unit.InScope("asdioasjdasodij"), Equals, false)
 }
+
+func (s *variableSuite) Test_Variable_GetStaticType(c *C) {
+	testCases := map[string]string{
+		"string":  "string",
+		"integer": "integer",
+		"float":   "float",
+		"bool":    "bool",
+		"list":    "list[string]",
+		"version": "string",
+	}
+	for typ, expected := range testCases {
+		unit, err := NewVariableFromString("test", typ)
+		c.Assert(err, IsNil)
+		c.Assert(unit.GetStaticType().String(), Equals, expected, Commentf("Type '%s'", typ))
+	}
+	unit, err := NewVariableFromString("test", "list")
+	c.Assert(err, IsNil)
+	unit.Options = map[string]interface{}{"type": "integer"}
+	c.Assert(unit.GetStaticType().String(), Equals, "list[integer]")
+}
+
+func (s *variableSuite) Test_Variable_TypeCheckDefault(c *C) {
+	globals := map[string]*script.StaticType{
+		"this": script.NewMapStaticType(map[string]*script.StaticType{
+			"version": script.NewStaticType("string"),
+			"inputs": script.NewMapStaticType(map[string]*script.StaticType{
+				"count": script.NewStaticType("integer"),
+			}),
+		}),
+	}
+	testCases := []interface{}{
+		"plain string",
+		12,
+		"$this.version.upper()",
+		"$this.inputs.count + 1",
+		[]interface{}{"$this.version", 12},
+	}
+	for _, test := range testCases {
+		unit, err := NewVariableFromString("test", "integer")
+		c.Assert(err, IsNil)
+		unit.Default = test
+		c.Assert(unit.TypeCheckDefault(globals), IsNil, Commentf("Default '%v'", test))
+	}
+	failingCases := map[string]string{
+		"$this.inputs.count.upper()": "Type error in default field of variable 'test': 'upper' expects string for argument 1, got integer in '$this.inputs.count.upper()'",
+		"$this.inputs.unknown":       "Type error in default field of variable 'test': Field 'unknown' was not found (count) in '$this.inputs.unknown'",
+		"$this.inputs.count > 1":     "Expecting integer in default field of variable 'test', but expression has type bool in '$this.inputs.count > 1'",
+	}
+	for test, expected := range failingCases {
+		unit, err := NewVariableFromString("test", "integer")
+		c.Assert(err, IsNil)
+		unit.Default = test
+		err = unit.TypeCheckDefault(globals)
+		c.Assert(err, Not(IsNil), Commentf("Default '%s'", test))
+		c.Assert(err.Error(), Equals, expected)
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package variable_types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var floatType = NewUserManagedVariableType("float", validateFloat)
+
+func validateFloat(value interface{}, options map[string]interface{}) (interface{}, error) {
+	switch value.(type) {
+	case float64:
+		return value.(float64), nil
+	case int:
+		return float64(value.(int)), nil
+	case string:
+		f, err := strconv.ParseFloat(value.(string), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Expecting 'float' value, but got 'string'")
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("Expecting 'float' value, but got '%T'", value)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package variable_types
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *variableSuite) Test_ValidateFloat(c *C) {
+	testCases := map[interface{}]float64{
+		0:        0.0,
+		1:        1.0,
+		-1000:    -1000.0,
+		0.5:      0.5,
+		-1000.25: -1000.25,
+		"0":      0.0,
+		"0.5":    0.5,
+		"-1e3":   -1000.0,
+	}
+	for testCase, expected := range testCases {
+		result, err := validateFloat(testCase, nil)
+		c.Assert(err, IsNil)
+		c.Assert(result, Equals, expected, Commentf("'%v' should be '%v'", testCase, expected))
+	}
+}
+
+func (s *variableSuite) Test_ValidateFloat_fails_on_invalid_values(c *C) {
+	_, err := validateFloat("half", nil)
+	c.Assert(err, ErrorMatches, "Expecting 'float' value, but got 'string'")
+	_, err = validateFloat(true, nil)
+	c.Assert(err, ErrorMatches, "Expecting 'float' value, but got 'bool'")
+}
@@ -18,6 +18,7 @@ package variable_types
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 )
 
@@ -28,7 +29,11 @@ func validateInt(value interface{}, options map[string]interface{}) (interface{}
 	case int:
 		return value.(int), nil
 	case float64:
-		return int(value.(float64)), nil
+		f := value.(float64)
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("Expecting 'integer' value, but got 'float' (%v)", f)
+		}
+		return int(f), nil
 	case string:
 		i, err := strconv.Atoi(value.(string))
 		if err != nil {
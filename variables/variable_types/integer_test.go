@@ -41,3 +41,8 @@ func (s *variableSuite) Test_ValidateInt(c *C) {
 		c.Assert(result, Equals, expected, Commentf("'%v' should be '%v'", testCase, expected))
 	}
 }
+
+func (s *variableSuite) Test_ValidateInt_fails_on_fractional_float(c *C) {
+	_, err := validateInt(0.5, nil)
+	c.Assert(err, ErrorMatches, "Expecting 'integer' value, but got 'float' \\(0.5\\)")
+}
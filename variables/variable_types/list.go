@@ -55,14 +55,17 @@ func validateList(value interface{}, options map[string]interface{}) (interface{
 				}
 				result = append(result, str)
 			case int, float64:
-				if valueType != "integer" {
+				numberType := integerType
+				if valueType == "float" {
+					numberType = floatType
+				} else if valueType != "integer" {
 					return nil, errors.New("Unexpected 'integer' value in list, expecting '" + valueType.(string) + "'")
 				}
-				str, err := integerType.Validate(val, nil)
+				number, err := numberType.Validate(val, nil)
 				if err != nil {
 					return nil, err
 				}
-				result = append(result, str)
+				result = append(result, number)
 			}
 		}
 		return result, nil
@@ -52,3 +52,9 @@ func (s *variableSuite) Test_ValidateList_json_string(c *C) {
 	c.Assert(lst, HasLen, 2)
 	c.Assert(lst, DeepEquals, []interface{}{"test", "test2"})
 }
+
+func (s *variableSuite) Test_ValidateList_float_list(c *C) {
+	lst, err := validateList("[1, 0.5]", map[string]interface{}{"type": "float"})
+	c.Assert(err, IsNil)
+	c.Assert(lst, DeepEquals, []interface{}{1.0, 0.5})
+}
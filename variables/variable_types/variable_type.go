@@ -26,7 +26,7 @@ var projectType = NewMagicVariable("project", "$this.project")
 var deploymentType = NewMagicVariable("deployment", "$this.deployment")
 var environmenType = NewMagicVariable("environment", "$this.environment")
 
-var knownTypes = []*VariableType{stringType, boolType, integerType, listType,
+var knownTypes = []*VariableType{stringType, boolType, integerType, floatType, listType,
 	versionType, clientType, projectType, deploymentType, environmenType}
 
 type Validator func(value interface{}, options map[string]interface{}) (interface{}, error)